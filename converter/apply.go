@@ -0,0 +1,183 @@
+package converter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/miekg/dns"
+)
+
+const (
+	defaultApplyPollInterval = 5 * time.Second
+	defaultApplyTimeout      = 10 * time.Minute
+)
+
+// applier pushes the records of a zone file to a Route 53 hosted zone. It
+// shows the changes as a plan, and only submits them once confirmed.
+type applier struct {
+	client       *route53Client
+	in           io.Reader
+	out          io.Writer
+	autoApprove  bool
+	pollInterval time.Duration
+	timeout      time.Duration
+	diag         *diagnostics
+}
+
+func newApplier(client *route53Client, in io.Reader, out io.Writer, autoApprove bool, diag *diagnostics) *applier {
+	return &applier{
+		client:       client,
+		in:           in,
+		out:          out,
+		autoApprove:  autoApprove,
+		pollInterval: defaultApplyPollInterval,
+		timeout:      defaultApplyTimeout,
+		diag:         diag,
+	}
+}
+
+// apply makes the record sets of the hosted zone match the records, creating,
+// deleting and modifying sets as needed. The apex SOA and NS record sets,
+// which Route 53 manages, record sets of excluded types, and alias or routing
+// policy record sets, which zone files cannot describe, are left alone. When
+// zoneID is empty, the hosted zone is looked up by the domain name.
+//
+// Each batch is applied by Route 53 as a whole, but batches are submitted one
+// after another. A failed batch stops the apply, leaving the batches before it
+// applied, so that applying again plans only the remaining changes.
+func (a *applier) apply(domain, zoneID string, records map[recordKey]dnsRecord, excludedTypes map[uint16]bool) error {
+	apex := dns.Fqdn(strings.ToLower(domain))
+	if zoneID == "" {
+		var err error
+		zoneID, err = a.client.findHostedZone(apex)
+		if err != nil {
+			return err
+		}
+	}
+
+	sets, err := a.client.listRecordSets(zoneID)
+	if err != nil {
+		return err
+	}
+	current := make(map[recordKey]dnsRecord)
+	for _, set := range sets {
+		rec := dnsRecord{
+			Name: strings.ToLower(unescapeRoute53Name(aws.ToString(set.Name))),
+			Type: string(set.Type),
+			TTL:  uint32(aws.ToInt64(set.TTL)),
+		}
+		if !a.manages(rec, apex, excludedTypes) {
+			continue
+		}
+		if set.AliasTarget != nil || set.SetIdentifier != nil {
+			a.diag.warnf("Leaving %s %s unchanged, as alias and routing policy record sets are not supported", rec.Name, rec.Type)
+			continue
+		}
+		for _, value := range set.ResourceRecords {
+			rec.Data = append(rec.Data, zoneValue(rec.Type, aws.ToString(value.Value)))
+		}
+		current[recordKey{rec.Name, rec.Type}] = rec
+	}
+
+	desired := make(map[recordKey]dnsRecord)
+	for key, rec := range records {
+		if !a.manages(rec, apex, excludedTypes) {
+			a.diag.recordWarnf(rec, "Skipping %s %s, which Route 53 manages itself", rec.Name, rec.Type)
+			continue
+		}
+		desired[key] = rec
+	}
+
+	changes := zoneDelta(current, desired)
+	if err := writeDeltaText(a.out, changes); err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	if !a.confirm(zoneID) {
+		return fmt.Errorf("Apply cancelled")
+	}
+
+	chunks := chunkChanges(changes)
+	var changeIDs []string
+	for idx, n := range chunks {
+		var batch []types.Change
+		for _, c := range changes[:n] {
+			if c.Old != nil {
+				batch = append(batch, types.Change{Action: types.ChangeActionDelete, ResourceRecordSet: apiRecordSet(*c.Old)})
+			}
+		}
+		for _, c := range changes[:n] {
+			if c.New != nil {
+				batch = append(batch, types.Change{Action: types.ChangeActionCreate, ResourceRecordSet: apiRecordSet(*c.New)})
+			}
+		}
+		changes = changes[n:]
+
+		changeID, err := a.client.changeRecordSets(zoneID, fmt.Sprintf("Applied by tfz53, batch %d of %d", idx+1, len(chunks)), batch)
+		if err != nil {
+			return fmt.Errorf("Batch %d of %d failed, after the batches before it were applied: %v", idx+1, len(chunks), err)
+		}
+		fmt.Fprintf(a.out, "Submitted batch %d of %d as change %s\n", idx+1, len(chunks), changeID)
+		changeIDs = append(changeIDs, changeID)
+	}
+
+	return a.waitInSync(changeIDs)
+}
+
+// manages reports whether the record set is in scope for apply.
+func (a *applier) manages(rec dnsRecord, apex string, excludedTypes map[uint16]bool) bool {
+	if excludedTypes[dns.StringToType[rec.Type]] {
+		return false
+	}
+	return rec.Name != apex || (rec.Type != "SOA" && rec.Type != "NS")
+}
+
+func (a *applier) confirm(zoneID string) bool {
+	if a.autoApprove {
+		return true
+	}
+	fmt.Fprintf(a.out, "Apply these changes to hosted zone %s? Only 'yes' will be accepted: ", zoneID)
+	answer, _ := bufio.NewReader(a.in).ReadString('\n')
+	return strings.TrimSpace(answer) == "yes"
+}
+
+// waitInSync polls the changes until Route 53 reports them propagated.
+func (a *applier) waitInSync(changeIDs []string) error {
+	deadline := time.Now().Add(a.timeout)
+	for _, changeID := range changeIDs {
+		for {
+			status, err := a.client.changeStatus(changeID)
+			if err != nil {
+				return err
+			}
+			if status == types.ChangeStatusInsync {
+				break
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("Change %s still %s after %s", changeID, status, a.timeout)
+			}
+			time.Sleep(a.pollInterval)
+		}
+	}
+	fmt.Fprintln(a.out, "All changes are in sync")
+	return nil
+}
+
+func apiRecordSet(rec dnsRecord) *types.ResourceRecordSet {
+	set := &types.ResourceRecordSet{
+		Name: aws.String(rec.Name),
+		Type: types.RRType(rec.Type),
+		TTL:  aws.Int64(int64(rec.TTL)),
+	}
+	for _, d := range rec.Data {
+		set.ResourceRecords = append(set.ResourceRecords, types.ResourceRecord{Value: aws.String(route53Value(rec.Type, d))})
+	}
+	return set
+}
@@ -0,0 +1,179 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	azureZoneTemplateStr = `resource "azurerm_dns_zone" "{{ .ID }}" {
+  name                = "{{ .Domain }}"
+  resource_group_name = "{{ .ResourceGroup }}"
+}
+`
+	azureRecordTemplateStr = `{{- range .Record.Comments }}
+# {{ . }}{{ end }}
+resource "{{ .ResourceType }}" "{{ .ResourceID }}" {
+  name                = "{{ .Name }}"
+  zone_name           = {{ .ZoneReference }}
+  resource_group_name = {{ reference (printf "azurerm_dns_zone.%s.resource_group_name" .ZoneID) }}
+  ttl                 = {{ .Record.TTL }}
+{{- if eq .Record.Type "CNAME" }}
+  record              = {{ index .Values 0 }}
+{{- else if .Values }}
+  records             = [{{ range $idx, $elem := .Values }}{{ if $idx }}, {{ end }}{{ $elem }}{{ end }}]
+{{- end }}
+{{- range .Blocks }}
+
+  record {
+{{- range . }}
+    {{ .Key }} = {{ .Value }}
+{{- end }}
+  }
+{{- end }}
+}
+`
+)
+
+func init() {
+	registerProvider(func() providerTarget { return &azureTarget{} })
+}
+
+// azureTarget generates azurerm_dns_zone and per-type azurerm_dns_*_record
+// resources for Azure DNS.
+type azureTarget struct {
+	resourceGroup string
+}
+
+// azureResourceTypes maps the record types supported by Azure DNS to the
+// Terraform resource type managing them.
+var azureResourceTypes = map[string]string{
+	"A":     "azurerm_dns_a_record",
+	"AAAA":  "azurerm_dns_aaaa_record",
+	"CAA":   "azurerm_dns_caa_record",
+	"CNAME": "azurerm_dns_cname_record",
+	"MX":    "azurerm_dns_mx_record",
+	"NS":    "azurerm_dns_ns_record",
+	"PTR":   "azurerm_dns_ptr_record",
+	"SRV":   "azurerm_dns_srv_record",
+	"TXT":   "azurerm_dns_txt_record",
+}
+
+func (t *azureTarget) String() string {
+	return "azure"
+}
+
+func (t *azureTarget) templates() (string, string) {
+	return azureZoneTemplateStr, azureRecordTemplateStr
+}
+
+func (t *azureTarget) prepareZone(zone *zoneTemplateData) {
+	zone.ResourceGroup = t.resourceGroup
+}
+
+func (t *azureTarget) zoneReference(zoneID string) string {
+	return fmt.Sprintf("azurerm_dns_zone.%s.name", zoneID)
+}
+
+func (t *azureTarget) supportsType(rrType string) bool {
+	_, ok := azureResourceTypes[rrType]
+	return ok
+}
+
+func (t *azureTarget) groupRecords(record dnsRecord, diag *diagnostics) []dnsRecord {
+	return groupByRecordSet(record, diag)
+}
+
+func (t *azureTarget) recordName(name, domain string) string {
+	return relativeName(name, domain)
+}
+
+func (t *azureTarget) resourceID(setID string, record dnsRecord) string {
+	return setID
+}
+
+// encodeRecord populates plain values for A, AAAA, NS and PTR records, the
+// single target of a CNAME, or record blocks for MX, SRV, CAA and TXT.
+func (t *azureTarget) encodeRecord(data *recordTemplateData, diag *diagnostics) error {
+	record := data.Record
+	data.ResourceType = azureResourceTypes[record.Type]
+
+	switch record.Type {
+	case "A", "AAAA", "NS", "PTR":
+		for _, d := range record.Data {
+			data.Values = append(data.Values, ensureQuoted(d))
+		}
+	case "CNAME":
+		if len(record.Data) != 1 {
+			return fmt.Errorf("CNAME %s has %d values, Azure DNS allows exactly one", record.Name, len(record.Data))
+		}
+		data.Values = []string{ensureQuoted(record.Data[0])}
+	case "TXT":
+		for _, d := range record.Data {
+			data.Blocks = append(data.Blocks, alignAttributes([]attribute{
+				{"value", txtValue(d)},
+			}))
+		}
+	default:
+		for _, d := range record.Data {
+			block, err := azureRecordBlock(record.Type, d)
+			if err != nil {
+				return err
+			}
+			data.Blocks = append(data.Blocks, alignAttributes(block))
+		}
+	}
+	return nil
+}
+
+func (t *azureTarget) nameServers(zoneID string) string {
+	return fmt.Sprintf("azurerm_dns_zone.%s.name_servers", zoneID)
+}
+
+// nameServerTemplate is empty, as Azure DNS creates the apex NS record set
+// with the zone, and azurerm_dns_ns_record cannot be created over it without
+// importing it first.
+func (t *azureTarget) nameServerTemplate() string {
+	return ""
+}
+
+func (t *azureTarget) handlingNotes(record dnsRecord) []string {
+	if record.Type == "TXT" {
+		return []string{"character-strings are joined into a single value, which Azure DNS splits by itself"}
+	}
+	return nil
+}
+
+// azureRecordBlock parses the data of an MX, SRV or CAA record into the
+// attributes of an Azure record block.
+func azureRecordBlock(rrType, data string) ([]attribute, error) {
+	rr, err := parseRecordData(rrType, data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch rr := rr.(type) {
+	case *dns.MX:
+		return []attribute{
+			{"preference", strconv.Itoa(int(rr.Preference))},
+			{"exchange", ensureQuoted(rr.Mx)},
+		}, nil
+	case *dns.SRV:
+		return []attribute{
+			{"priority", strconv.Itoa(int(rr.Priority))},
+			{"weight", strconv.Itoa(int(rr.Weight))},
+			{"port", strconv.Itoa(int(rr.Port))},
+			{"target", ensureQuoted(rr.Target)},
+		}, nil
+	case *dns.CAA:
+		return []attribute{
+			{"flags", strconv.Itoa(int(rr.Flag))},
+			{"tag", ensureQuoted(rr.Tag)},
+			{"value", fmt.Sprintf("%q", rr.Value)},
+		}, nil
+	default:
+		return nil, fmt.Errorf("Cannot create Azure record block for %s data %q", rrType, data)
+	}
+}
@@ -0,0 +1,106 @@
+package converter
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// canonicalData returns the data of a record in the canonical form Route 53
+// returns it in, so that values read back from a provider compare equal to
+// the zone file: domain names and CAA tags are lowercased, IPv6 addresses
+// are compressed, and the character-strings of TXT records are escaped
+// consistently. Other types are left as formatted by miekg/dns.
+func canonicalData(rr dns.RR) string {
+	rr = dns.Copy(rr)
+	switch rr := rr.(type) {
+	case *dns.AAAA:
+		return canonicalIPv6(rr.AAAA)
+	case *dns.CNAME:
+		rr.Target = strings.ToLower(rr.Target)
+	case *dns.DNAME:
+		rr.Target = strings.ToLower(rr.Target)
+	case *dns.MX:
+		rr.Mx = strings.ToLower(rr.Mx)
+	case *dns.NS:
+		rr.Ns = strings.ToLower(rr.Ns)
+	case *dns.PTR:
+		rr.Ptr = strings.ToLower(rr.Ptr)
+	case *dns.SRV:
+		rr.Target = strings.ToLower(rr.Target)
+	case *dns.NAPTR:
+		rr.Replacement = strings.ToLower(rr.Replacement)
+	case *dns.SOA:
+		rr.Ns = strings.ToLower(rr.Ns)
+		rr.Mbox = strings.ToLower(rr.Mbox)
+	case *dns.CAA:
+		rr.Tag = strings.ToLower(rr.Tag)
+	case *dns.TXT:
+		return formatTXT(txtFromParsed(rr.Txt), false)
+	case *dns.SPF:
+		return formatTXT(txtFromParsed(rr.Txt), false)
+	}
+	return strings.TrimPrefix(rr.String(), rr.Header().String())
+}
+
+// canonicalValue returns record data written elsewhere than in a zone file,
+// such as by hand in a Terraform configuration, in the canonical form of
+// canonicalData. Data that cannot be parsed is returned unchanged.
+func canonicalValue(rrType, data string) string {
+	rr, err := parseRecordData(rrType, data)
+	if err != nil {
+		return data
+	}
+	return canonicalData(rr)
+}
+
+// canonicalIPv6 formats an IPv6 address as in RFC 5952. Unlike net.IP, it
+// keeps IPv4-mapped addresses in IPv6 notation.
+func canonicalIPv6(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return "::ffff:" + v4.String()
+	}
+	return ip.String()
+}
+
+// sortValues sorts the values of a record set, comparing them field by field
+// so that numbers, such as MX preferences, and IP addresses sort by value.
+func sortValues(values []string) {
+	sort.SliceStable(values, func(i, j int) bool {
+		return compareValues(values[i], values[j]) < 0
+	})
+}
+
+func compareValues(a, b string) int {
+	fa, fb := strings.Fields(a), strings.Fields(b)
+	for i := 0; i < len(fa) && i < len(fb); i++ {
+		if c := compareFields(fa[i], fb[i]); c != 0 {
+			return c
+		}
+	}
+	return len(fa) - len(fb)
+}
+
+func compareFields(a, b string) int {
+	if na, err := strconv.ParseUint(a, 10, 64); err == nil {
+		if nb, err := strconv.ParseUint(b, 10, 64); err == nil {
+			switch {
+			case na < nb:
+				return -1
+			case na > nb:
+				return 1
+			}
+			return 0
+		}
+	}
+	if ipa, ipb := net.ParseIP(a), net.ParseIP(b); ipa != nil && ipb != nil {
+		if c := bytes.Compare(ipa.To16(), ipb.To16()); c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(a, b)
+}
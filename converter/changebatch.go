@@ -0,0 +1,56 @@
+package converter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// changeBatchWriter writes a zone as Route 53 ChangeBatch documents, as
+// accepted by `aws route53 change-resource-record-sets`, upserting every
+// record set. Each batch fits in a single change request, and the batches are
+// written one after another.
+type changeBatchWriter struct{}
+
+func (c *changeBatchWriter) writeZone(domain string, records map[recordKey]dnsRecord, w io.Writer, diag *diagnostics) error {
+	zoneName := strings.TrimRight(domain, ".")
+
+	recordSets := make([]dnsRecord, 0, len(records))
+	for _, key := range sortedRecordKeys(records) {
+		recordSets = append(recordSets, records[key])
+	}
+
+	chunks := chunkRecordSets(recordSets, route53UpsertWeight, diag)
+	for idx, chunk := range chunks {
+		changes := make([]interface{}, len(chunk))
+		for i, rec := range chunk {
+			changes[i] = changeBatchItem("UPSERT", rec)
+		}
+
+		batch := yamlMap{
+			{"Comment", fmt.Sprintf("Restore of %s by tfz53, batch %d of %d", zoneName, idx+1, len(chunks))},
+			{"Changes", changes},
+		}
+		if err := writeJSON(w, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changeBatchItem describes the action on a record set in a ChangeBatch.
+func changeBatchItem(action string, rec dnsRecord) yamlMap {
+	values := make([]interface{}, len(rec.Data))
+	for i, d := range rec.Data {
+		values[i] = yamlMap{{"Value", route53Value(rec.Type, d)}}
+	}
+	return yamlMap{
+		{"Action", action},
+		{"ResourceRecordSet", yamlMap{
+			{"Name", rec.Name},
+			{"Type", rec.Type},
+			{"TTL", rec.TTL},
+			{"ResourceRecords", values},
+		}},
+	}
+}
@@ -0,0 +1,202 @@
+package converter
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	cloudflareZoneTemplateStr = `resource "cloudflare_zone" "{{ .ID }}" {
+  zone = "{{ .Domain }}"
+}
+`
+	cloudflareRecordTemplateStr = `{{- range .Record.Comments }}
+# {{ . }}{{ end }}
+resource "cloudflare_record" "{{ .ResourceID }}" {
+{{- range .Attributes }}
+  {{ .Key }} = {{ .Value }}
+{{- end }}
+{{- range .Blocks }}
+
+  data {
+{{- range . }}
+    {{ .Key }} = {{ .Value }}
+{{- end }}
+  }
+{{- end }}
+}
+`
+)
+
+// cloudflareProxiedAnnotation marks a record set as proxied through Cloudflare
+// when it appears in the comment of any of its lines, for example:
+//
+//	www  IN  A  192.0.2.1 ; tfz53:proxied
+//
+// It applies to every value of the record set, including those on lines
+// without it.
+const cloudflareProxiedAnnotation = annotationPrefix + "proxied"
+
+// cloudflareSupportedTypes lists the record types tfz53 can represent as
+// cloudflare_record resources.
+var cloudflareSupportedTypes = map[string]bool{
+	"A":     true,
+	"AAAA":  true,
+	"CAA":   true,
+	"CNAME": true,
+	"MX":    true,
+	"NS":    true,
+	"PTR":   true,
+	"SRV":   true,
+	"TLSA":  true,
+	"TXT":   true,
+}
+
+// cloudflareProxiableTypes lists the record types Cloudflare can proxy.
+var cloudflareProxiableTypes = map[string]bool{
+	"A":     true,
+	"AAAA":  true,
+	"CNAME": true,
+}
+
+func init() {
+	registerProvider(func() providerTarget { return &cloudflareTarget{} })
+}
+
+// cloudflareTarget generates cloudflare_zone and cloudflare_record resources.
+// Cloudflare does not group values into record sets, so every value becomes a
+// resource of its own.
+type cloudflareTarget struct{}
+
+func (t *cloudflareTarget) String() string {
+	return "cloudflare"
+}
+
+func (t *cloudflareTarget) templates() (string, string) {
+	return cloudflareZoneTemplateStr, cloudflareRecordTemplateStr
+}
+
+func (t *cloudflareTarget) prepareZone(zone *zoneTemplateData) {}
+
+func (t *cloudflareTarget) zoneReference(zoneID string) string {
+	return fmt.Sprintf("cloudflare_zone.%s.id", zoneID)
+}
+
+func (t *cloudflareTarget) supportsType(rrType string) bool {
+	return cloudflareSupportedTypes[rrType]
+}
+
+func (t *cloudflareTarget) groupRecords(record dnsRecord, diag *diagnostics) []dnsRecord {
+	return groupByValue(record, diag)
+}
+
+func (t *cloudflareTarget) recordName(name, domain string) string {
+	return relativeName(name, domain)
+}
+
+func (t *cloudflareTarget) resourceID(setID string, record dnsRecord) string {
+	return valueResourceID(setID, record)
+}
+
+func (t *cloudflareTarget) encodeRecord(data *recordTemplateData, diag *diagnostics) error {
+	record := data.Record
+	proxied := hasAnnotation(record, cloudflareProxiedAnnotation)
+	if proxied && !cloudflareProxiableTypes[record.Type] {
+		diag.recordWarnf(record, "Cloudflare cannot proxy %s records, ignoring annotation on %s", record.Type, record.Name)
+		proxied = false
+	}
+
+	// Proxied records always use Cloudflare's automatic TTL
+	ttl := strconv.Itoa(int(record.TTL))
+	if proxied {
+		ttl = "1"
+	}
+
+	valueAttrs, dataAttrs, err := cloudflareRecordValue(record, record.Data[0])
+	if err != nil {
+		return err
+	}
+
+	attrs := []attribute{
+		{"zone_id", data.ZoneReference},
+		{"name", ensureQuoted(data.Name)},
+		{"type", ensureQuoted(record.Type)},
+	}
+	attrs = append(attrs, valueAttrs...)
+	attrs = append(attrs,
+		attribute{"ttl", ttl},
+		attribute{"proxied", strconv.FormatBool(proxied)},
+	)
+	data.Attributes = alignAttributes(attrs)
+	if dataAttrs != nil {
+		data.Blocks = [][]attribute{alignAttributes(dataAttrs)}
+	}
+	return nil
+}
+
+// cloudflareRecordValue converts a single record value to the attributes of a
+// cloudflare_record. Simple types return a value attribute, MX additionally
+// returns a priority, while SRV, CAA and TLSA return the attributes of a
+// data block.
+func cloudflareRecordValue(record dnsRecord, value string) ([]attribute, []attribute, error) {
+	switch record.Type {
+	case "A", "AAAA":
+		return []attribute{{"value", ensureQuoted(value)}}, nil, nil
+	case "CNAME", "NS", "PTR":
+		return []attribute{{"value", ensureQuoted(strings.TrimRight(value, "."))}}, nil, nil
+	case "TXT":
+		return []attribute{{"value", txtValue(value)}}, nil, nil
+	}
+
+	rr, err := parseRecordData(record.Type, value)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch rr := rr.(type) {
+	case *dns.MX:
+		return []attribute{
+			{"value", ensureQuoted(strings.TrimRight(rr.Mx, "."))},
+			{"priority", strconv.Itoa(int(rr.Preference))},
+		}, nil, nil
+	case *dns.SRV:
+		labels := dns.SplitDomainName(record.Name)
+		if len(labels) < 3 || !strings.HasPrefix(labels[0], "_") || !strings.HasPrefix(labels[1], "_") {
+			return nil, nil, fmt.Errorf("SRV record %s is not of the form _service._proto.name", record.Name)
+		}
+		return nil, []attribute{
+			{"service", ensureQuoted(labels[0])},
+			{"proto", ensureQuoted(labels[1])},
+			{"name", ensureQuoted(strings.Join(labels[2:], "."))},
+			{"priority", strconv.Itoa(int(rr.Priority))},
+			{"weight", strconv.Itoa(int(rr.Weight))},
+			{"port", strconv.Itoa(int(rr.Port))},
+			{"target", ensureQuoted(strings.TrimRight(rr.Target, "."))},
+		}, nil
+	case *dns.CAA:
+		return nil, []attribute{
+			{"flags", strconv.Itoa(int(rr.Flag))},
+			{"tag", ensureQuoted(rr.Tag)},
+			{"value", fmt.Sprintf("%q", rr.Value)},
+		}, nil
+	case *dns.TLSA:
+		return nil, []attribute{
+			{"usage", strconv.Itoa(int(rr.Usage))},
+			{"selector", strconv.Itoa(int(rr.Selector))},
+			{"matching_type", strconv.Itoa(int(rr.MatchingType))},
+			{"certificate", ensureQuoted(rr.Certificate)},
+		}, nil
+	default:
+		return nil, nil, fmt.Errorf("Cannot create Cloudflare record for %s data %q", record.Type, value)
+	}
+}
+
+// valueHash returns a short, stable identifier for a record value.
+func valueHash(value string) string {
+	sum := sha1.Sum([]byte(value))
+	return hex.EncodeToString(sum[:4])
+}
@@ -0,0 +1,362 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// cloudFormationMaxResources is the number of resources a template may
+	// declare.
+	cloudFormationMaxResources = 500
+	// cloudFormationMaxBodySize is the largest template CloudFormation
+	// accepts inline, larger ones must be uploaded to S3.
+	cloudFormationMaxBodySize = 51200
+	// cloudFormationMaxS3BodySize is the largest template CloudFormation
+	// accepts from S3.
+	cloudFormationMaxS3BodySize = 1000000
+
+	cloudFormationZoneID = "HostedZone"
+)
+
+// cloudFormationWriter writes a zone as a CloudFormation template with an
+// AWS::Route53::HostedZone, and either one AWS::Route53::RecordSet per record
+// set, named after the naming strategy, or AWS::Route53::RecordSetGroup
+// resources holding several each. Zones too large for a single template are
+// split over several YAML documents, each small enough to be deployed inline.
+// The first declares the hosted zone, and the others take its ID as a
+// parameter.
+type cloudFormationWriter struct {
+	json            bool
+	recordSetGroups bool
+	naming          string
+}
+
+func (c *cloudFormationWriter) writeZone(domain string, records map[recordKey]dnsRecord, w io.Writer, diag *diagnostics) error {
+	zoneName := strings.TrimRight(domain, ".")
+	keys := sortedRecordKeys(records)
+
+	var templates []yamlMap
+	if c.recordSetGroups {
+		recordSets := make([]dnsRecord, len(keys))
+		for i, key := range keys {
+			recordSets[i] = records[key]
+		}
+		templates = c.groupTemplates(zoneName, recordSets, diag)
+	} else {
+		namer, err := newResourceNamer(c.naming, domain)
+		if err != nil {
+			return err
+		}
+		if err := namer.assign(keys, diag); err != nil {
+			return err
+		}
+		ids := logicalIDs(keys, namer)
+		resources := make(yamlMap, len(keys))
+		for i, key := range keys {
+			resources[i] = yamlField{ids[key], yamlMap{
+				{"Type", "AWS::Route53::RecordSet"},
+				{"Properties", c.recordSetProperties(records[key], true)},
+			}}
+		}
+		templates = c.splitTemplates(zoneName, resources)
+	}
+
+	if len(templates) > 1 && c.json {
+		// A JSON stream holds a single document, so there is no file set to
+		// split the templates into
+		diag.errorf("The zone needs %d templates, which cannot be written as JSON. Use -format cloudformation-yaml, which writes them as separate documents", len(templates))
+		return nil
+	}
+	if len(templates) > 1 {
+		diag.add(Info, "", 0, "Splitting the zone over %d templates of at most %d bytes. Deploy the first, then the others with its HostedZoneId output as their HostedZone parameter", len(templates), cloudFormationMaxBodySize)
+	}
+	for i, template := range templates {
+		var buf bytes.Buffer
+		if err := c.write(&buf, template); err != nil {
+			return err
+		}
+		switch {
+		case buf.Len() > cloudFormationMaxS3BodySize:
+			diag.warnf("Template %d is %d bytes, more than the %d CloudFormation allows", i+1, buf.Len(), cloudFormationMaxS3BodySize)
+		case buf.Len() > cloudFormationMaxBodySize:
+			diag.warnf("Template %d is %d bytes and must be uploaded to S3 to be deployed", i+1, buf.Len())
+		}
+		if i > 0 {
+			// Templates after the first are further documents of the stream
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := buf.WriteTo(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// template returns a template declaring the resources. The first template
+// declares the hosted zone, with outputs for its ID and name servers, while
+// the others take its ID as a parameter of the same name, so that their
+// references to it are unchanged.
+func (c *cloudFormationWriter) template(zoneName string, first bool, resources yamlMap) yamlMap {
+	if !first {
+		return yamlMap{
+			{"AWSTemplateFormatVersion", "2010-09-09"},
+			{"Description", fmt.Sprintf("Route 53 record sets for %s, generated by tfz53", zoneName)},
+			{"Parameters", yamlMap{
+				{cloudFormationZoneID, yamlMap{
+					{"Type", "AWS::Route53::HostedZone::Id"},
+					{"Description", "ID of the hosted zone, the HostedZoneId output of the first template"},
+				}},
+			}},
+			{"Resources", resources},
+		}
+	}
+
+	zone := yamlMap{
+		{cloudFormationZoneID, yamlMap{
+			{"Type", "AWS::Route53::HostedZone"},
+			{"Properties", yamlMap{
+				{"Name", zoneName},
+			}},
+		}},
+	}
+	return yamlMap{
+		{"AWSTemplateFormatVersion", "2010-09-09"},
+		{"Description", fmt.Sprintf("Route 53 hosted zone for %s, generated by tfz53", zoneName)},
+		{"Resources", append(zone, resources...)},
+		{"Outputs", yamlMap{
+			{"HostedZoneId", yamlMap{
+				{"Description", "ID of the hosted zone"},
+				{"Value", c.ref(cloudFormationZoneID)},
+			}},
+			{"NameServers", yamlMap{
+				{"Description", "Name servers of the hosted zone"},
+				{"Value", c.join(",", c.getAtt(cloudFormationZoneID, "NameServers"))},
+			}},
+		}},
+	}
+}
+
+func (c *cloudFormationWriter) write(w io.Writer, template yamlMap) error {
+	if c.json {
+		return writeJSON(w, template)
+	}
+	return writeYAML(w, template)
+}
+
+// bodySize returns the size of the template as written.
+func (c *cloudFormationWriter) bodySize(template yamlMap) int {
+	var buf bytes.Buffer
+	c.write(&buf, template)
+	return buf.Len()
+}
+
+// templateSizes measures templates without resources of their own. A resource
+// adds the same number of bytes to any template, as they are all written at
+// the same indentation, so the size of a template is that of the first or a
+// later one without resources, plus the sizes of its resources.
+type templateSizes struct {
+	c           *cloudFormationWriter
+	zoneName    string
+	first, rest int
+}
+
+func (c *cloudFormationWriter) templateSizes(zoneName string) templateSizes {
+	sizes := templateSizes{c: c, zoneName: zoneName}
+	sizes.first = c.bodySize(c.template(zoneName, true, nil))
+	probe := yamlField{"Probe", "x"}
+	sizes.rest = c.bodySize(c.template(zoneName, false, yamlMap{probe})) - sizes.resource(probe)
+	return sizes
+}
+
+// resource returns the number of bytes the resource adds to a template.
+func (s templateSizes) resource(resource yamlField) int {
+	return s.c.bodySize(s.c.template(s.zoneName, true, yamlMap{resource})) - s.first
+}
+
+// templatePacker fills templates with resources, starting a new one when the
+// next resource would make the template too large to be deployed inline, or
+// declare more resources than allowed. The first template may be left with
+// only the hosted zone, when the resource only fits in one without it.
+type templatePacker struct {
+	sizes        templateSizes
+	templates    []yamlMap
+	current      yamlMap
+	size         int
+	maxResources int
+}
+
+func newTemplatePacker(sizes templateSizes) *templatePacker {
+	return &templatePacker{sizes: sizes, size: sizes.first, maxResources: cloudFormationMaxResources - 1}
+}
+
+// reserve makes room for a resource of n bytes.
+func (p *templatePacker) reserve(n int) {
+	if (len(p.templates) == 0 || len(p.current) > 0) && (p.size+n > cloudFormationMaxBodySize || len(p.current) == p.maxResources) {
+		p.templates = append(p.templates, p.sizes.c.template(p.sizes.zoneName, len(p.templates) == 0, p.current))
+		p.current, p.size, p.maxResources = nil, p.sizes.rest, cloudFormationMaxResources
+	}
+	p.size += n
+}
+
+func (p *templatePacker) add(resource yamlField) {
+	p.current = append(p.current, resource)
+}
+
+func (p *templatePacker) finish() []yamlMap {
+	return append(p.templates, p.sizes.c.template(p.sizes.zoneName, len(p.templates) == 0, p.current))
+}
+
+// splitTemplates spreads the resources over as few templates as needed.
+func (c *cloudFormationWriter) splitTemplates(zoneName string, resources yamlMap) []yamlMap {
+	sizes := c.templateSizes(zoneName)
+	p := newTemplatePacker(sizes)
+	for _, resource := range resources {
+		p.reserve(sizes.resource(resource))
+		p.add(resource)
+	}
+	return p.finish()
+}
+
+// groupTemplates spreads the record sets over groups and templates. Each
+// group is applied as a single change request, which must stay within the
+// Route 53 limits for those, and must fit in a template. Groups are filled up
+// to either limit, so that the fewest templates are needed.
+func (c *cloudFormationWriter) groupTemplates(zoneName string, recordSets []dnsRecord, diag *diagnostics) []yamlMap {
+	sizes := c.templateSizes(zoneName)
+	p := newTemplatePacker(sizes)
+
+	var sets []interface{}
+	var change route53ChangeSize
+	groups := 0
+	closeGroup := func() {
+		groups++
+		p.add(c.recordSetGroup(groups, sets))
+		sets, change = nil, route53ChangeSize{}
+	}
+	for _, rec := range recordSets {
+		recChange := recordSetChangeSize(rec, route53UpsertWeight)
+		if recChange.exceedsLimits() {
+			diag.recordWarnf(rec, "%s %s exceeds the size limits of a Route 53 change request by itself", rec.Name, rec.Type)
+		}
+		item := c.recordSetProperties(rec, false)
+		n := sizes.resource(c.recordSetGroup(1, []interface{}{item, item})) - sizes.resource(c.recordSetGroup(1, []interface{}{item}))
+		if len(sets) > 0 && (change.add(recChange).exceedsLimits() || p.size+n > cloudFormationMaxBodySize) {
+			closeGroup()
+		}
+		if len(sets) == 0 {
+			// A new group adds its own properties along with the item, and
+			// cannot span templates
+			p.reserve(sizes.resource(c.recordSetGroup(groups+1, []interface{}{item})))
+		} else {
+			p.size += n
+		}
+		sets = append(sets, item)
+		change = change.add(recChange)
+	}
+	if len(sets) > 0 {
+		closeGroup()
+	}
+	return p.finish()
+}
+
+func (c *cloudFormationWriter) recordSetGroup(idx int, sets []interface{}) yamlField {
+	return yamlField{fmt.Sprintf("RecordSetGroup%d", idx), yamlMap{
+		{"Type", "AWS::Route53::RecordSetGroup"},
+		{"Properties", yamlMap{
+			{"HostedZoneId", c.ref(cloudFormationZoneID)},
+			{"RecordSets", sets},
+		}},
+	}}
+}
+
+// recordSetProperties describes a record set, either as the properties of a
+// RecordSet resource, which refer to the hosted zone, or as an item of a
+// RecordSetGroup.
+func (c *cloudFormationWriter) recordSetProperties(rec dnsRecord, withZone bool) yamlMap {
+	values := make([]interface{}, len(rec.Data))
+	for i, d := range rec.Data {
+		values[i] = route53Value(rec.Type, d)
+	}
+
+	props := yamlMap{}
+	if withZone {
+		props = append(props, yamlField{"HostedZoneId", c.ref(cloudFormationZoneID)})
+	}
+	return append(props,
+		yamlField{"Name", rec.Name},
+		yamlField{"Type", rec.Type},
+		yamlField{"TTL", fmt.Sprint(rec.TTL)},
+		yamlField{"ResourceRecords", values},
+	)
+}
+
+func (c *cloudFormationWriter) ref(logicalID string) interface{} {
+	if c.json {
+		return yamlMap{{"Ref", logicalID}}
+	}
+	return yamlTagged{"!Ref", logicalID}
+}
+
+func (c *cloudFormationWriter) getAtt(logicalID, attribute string) interface{} {
+	if c.json {
+		return yamlMap{{"Fn::GetAtt", []interface{}{logicalID, attribute}}}
+	}
+	return yamlTagged{"!GetAtt", logicalID + "." + attribute}
+}
+
+func (c *cloudFormationWriter) join(sep string, list interface{}) interface{} {
+	if c.json {
+		return yamlMap{{"Fn::Join", []interface{}{sep, list}}}
+	}
+	return yamlTagged{"!Join", []interface{}{sep, list}}
+}
+
+// logicalIDs derives CloudFormation logical IDs, which may only contain
+// letters and numbers, from the resource names of the naming strategy.
+// Removing the other characters can make distinct names collide, such as
+// a-b and a_b. All but the first of those get a number appended, skipping
+// the IDs other record sets have by themselves.
+func logicalIDs(keys []recordKey, namer *resourceNamer) map[recordKey]string {
+	natural := make(map[recordKey]string, len(keys))
+	taken := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		id := logicalID(namer.name(key))
+		natural[key] = id
+		taken[id] = true
+	}
+
+	ids := make(map[recordKey]string, len(keys))
+	next := make(map[string]int)
+	for _, key := range keys {
+		id := natural[key]
+		n, used := next[id]
+		if !used {
+			ids[key] = id
+			next[id] = 2
+			continue
+		}
+		for taken[fmt.Sprintf("%s%d", id, n)] {
+			n++
+		}
+		ids[key] = fmt.Sprintf("%s%d", id, n)
+		taken[ids[key]] = true
+		next[id] = n + 1
+	}
+	return ids
+}
+
+// logicalID joins the alphanumeric parts of a resource name, capitalized.
+func logicalID(name string) string {
+	id := "Record"
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
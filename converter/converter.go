@@ -0,0 +1,479 @@
+// Package converter converts DNS zone files to Terraform resources for several
+// DNS providers, and to other formats describing the zone, such as
+// CloudFormation templates. It also compares zone files with each other and
+// with Terraform, exports zone files from Terraform state, and applies them
+// to Route 53. The tfz53 command is a thin wrapper around it.
+//
+// A Converter only holds its options, so it can be used from concurrent
+// goroutines. Problems with single records do not fail a call, but are
+// returned as the diagnostics of its Result, located at the line of the
+// record in the zone file, and the records are left out.
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Options configure a Converter. Apart from Domain, the zero value of each
+// option selects the default of the tfz53 command, except for Exclude.
+type Options struct {
+	// Domain is the name of the zone. Required.
+	Domain string
+	// FileName names the zone file in errors. Defaults to the name of the
+	// file when reading from an *os.File.
+	FileName string
+	// Exclude lists the record types to ignore, such as SOA and NS. The
+	// command excludes those by default.
+	Exclude []string
+	// TTLPolicy resolves the conflicting TTLs of the records of a set: min,
+	// max, first, last, or fail to leave the set out. Defaults to first.
+	TTLPolicy string
+
+	// Syntax is the Terraform syntax to generate.
+	Syntax Syntax
+	// Naming is the strategy naming the resources: fqdn, relative, hash or
+	// a template. Defaults to fqdn.
+	Naming string
+	// Provider is the DNS provider to generate resources for. Defaults to
+	// route53.
+	Provider string
+	// AzureResourceGroup is the resource group of the zone, required for the
+	// azure provider.
+	AzureResourceGroup string
+	// Mirror is a second provider to serve the zone from, generating
+	// identical record sets for both.
+	Mirror string
+	// ExistingZone adds the records to an existing Route 53 hosted zone,
+	// looked up by name, instead of creating it.
+	ExistingZone bool
+	// Previous is a previously generated Terraform file, or its state, to
+	// move the resources of renamed records from. PreviousName names it in
+	// errors.
+	Previous     []byte
+	PreviousName string
+
+	// Format is the output format: terraform, cloudformation-yaml,
+	// cloudformation-json, pulumi-yaml or route53-changebatch. Defaults to
+	// terraform.
+	Format string
+	// RecordSetGroups groups the record sets of CloudFormation templates into
+	// AWS::Route53::RecordSetGroup resources.
+	RecordSetGroups bool
+
+	// Strict reports warnings as errors.
+	Strict bool
+}
+
+// Record is a record set of the zone file. Line is the line of its first
+// record, or 0 when not known.
+type Record struct {
+	Name     string
+	Type     string
+	TTL      uint32
+	Data     []string
+	Comments []string
+	Line     int
+}
+
+// Result is the outcome of a call. Output holds the complete output, and
+// Records the record sets of the zone file read.
+type Result struct {
+	Output  []byte
+	Records []Record
+
+	// MirrorReport is the consistency report of a mirrored conversion.
+	MirrorReport []byte
+	// MovedScript holds the terraform state mv commands moving resources in
+	// legacy syntax, which has no moved blocks.
+	MovedScript []byte
+	// Changes counts the record sets that differ, for Delta and Diff.
+	Changes int
+	// Findings are the problems Lint found in the zone, which Output
+	// reports.
+	Findings []Diagnostic
+
+	Diagnostics []Diagnostic
+}
+
+// Count returns the number of diagnostics of the given severity.
+func (res *Result) Count(severity Severity) int {
+	n := 0
+	for _, d := range res.Diagnostics {
+		if d.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+// Converter converts zone files as configured by its options.
+type Converter struct {
+	opts          Options
+	excludedTypes map[uint16]bool
+	ttlPolicy     ttlPolicy
+}
+
+// New validates the options and returns a Converter using them.
+func New(opts Options) (*Converter, error) {
+	if opts.Domain == "" {
+		return nil, fmt.Errorf("Domain is required")
+	}
+	if opts.Naming == "" {
+		opts.Naming = defaultNamingStrategy
+	}
+	if opts.Provider == "" {
+		opts.Provider = "route53"
+	}
+	if opts.Format == "" {
+		opts.Format = "terraform"
+	}
+	policy, err := ttlPolicyFromString(opts.TTLPolicy)
+	if err != nil {
+		return nil, err
+	}
+	c := &Converter{
+		opts:          opts,
+		excludedTypes: excludedTypesFromString(strings.Join(opts.Exclude, ",")),
+		ttlPolicy:     policy,
+	}
+
+	if _, err := newResourceNamer(opts.Naming, opts.Domain); err != nil {
+		return nil, err
+	}
+	if opts.Format != "terraform" {
+		if _, err := c.zoneWriter(); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	provider, err := c.configuredProvider(opts.Provider)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Mirror != "" {
+		mirror, err := c.configuredProvider(opts.Mirror)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := newMirroredGenerator(provider, mirror, opts.Syntax, c.newDiagnostics()); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Previous != nil && provider.String() != "route53" {
+		return nil, fmt.Errorf("Moving resources is not supported by the %s provider", provider)
+	}
+	return c, nil
+}
+
+// Convert generates the resources, or other output of the configured format,
+// for the zone file.
+func (c *Converter) Convert(r io.Reader) (*Result, error) {
+	diag := c.newDiagnostics()
+	records := readZoneRecords(r, c.opts.FileName, c.opts.Domain, c.excludedTypes, c.ttlPolicy, diag)
+	if c.targetsRoute53() {
+		validateRoute53(records, diag)
+	}
+	res := &Result{Records: exportedRecords(records)}
+
+	var output bytes.Buffer
+	if c.opts.Format != "terraform" {
+		w, err := c.zoneWriter()
+		if err != nil {
+			return nil, err
+		}
+		if err := w.writeZone(c.opts.Domain, records, &output, diag); err != nil {
+			return nil, err
+		}
+		return res.finish(output.Bytes(), diag), nil
+	}
+
+	provider, err := c.configuredProvider(c.opts.Provider)
+	if err != nil {
+		return nil, err
+	}
+	if c.opts.Mirror != "" {
+		mirror, err := c.configuredProvider(c.opts.Mirror)
+		if err != nil {
+			return nil, err
+		}
+		m, err := newMirroredGenerator(provider, mirror, c.opts.Syntax, diag)
+		if err != nil {
+			return nil, err
+		}
+		m.naming = c.opts.Naming
+		var report bytes.Buffer
+		if err := m.generateTerraformForZone(c.opts.Domain, records, &output, &report); err != nil {
+			return nil, err
+		}
+		res.MirrorReport = report.Bytes()
+		return res.finish(output.Bytes(), diag), nil
+	}
+
+	g := newConfigGenerator(provider, c.opts.Syntax, diag)
+	g.naming = c.opts.Naming
+	var movedScript bytes.Buffer
+	if c.opts.Previous != nil {
+		g.previous, err = readPreviousResources(c.opts.Previous, c.opts.PreviousName, c.opts.Domain, diag)
+		if err != nil {
+			return nil, err
+		}
+		g.movedScript = &movedScript
+	}
+	if err := g.generateTerraformForZone(c.opts.Domain, records, &output); err != nil {
+		return nil, err
+	}
+	res.MovedScript = movedScript.Bytes()
+	return res.finish(output.Bytes(), diag), nil
+}
+
+// Delta writes the changes from a previous version of the zone file, old, to
+// the zone file in the given format: text, json or route53-changebatch.
+func (c *Converter) Delta(old, r io.Reader, format string) (*Result, error) {
+	diag := c.newDiagnostics()
+	oldRecords := readZoneRecords(old, "", c.opts.Domain, c.excludedTypes, c.ttlPolicy, diag)
+	records := readZoneRecords(r, c.opts.FileName, c.opts.Domain, c.excludedTypes, c.ttlPolicy, diag)
+	changes := zoneDelta(oldRecords, records)
+
+	var output bytes.Buffer
+	if err := writeDelta(&output, format, c.opts.Domain, changes); err != nil {
+		return nil, err
+	}
+	res := &Result{Records: exportedRecords(records), Changes: len(changes)}
+	return res.finish(output.Bytes(), diag), nil
+}
+
+// Diff compares the zone file with the aws_route53_record resources generated
+// by tfz53 in the .tf files of the directory, reporting the record sets that
+// differ.
+func (c *Converter) Diff(r io.Reader, dir string) (*Result, error) {
+	diag := c.newDiagnostics()
+	namer, err := newResourceNamer(c.opts.Naming, c.opts.Domain)
+	if err != nil {
+		return nil, err
+	}
+	sets, err := readTerraformRecordSets(dir, c.excludedTypes, namer, diag)
+	if err != nil {
+		return nil, err
+	}
+	records := readZoneRecords(r, c.opts.FileName, c.opts.Domain, c.excludedTypes, c.ttlPolicy, diag)
+	changes := terraformDiff(sets, records)
+
+	var output bytes.Buffer
+	if err := writeTerraformDiff(&output, changes, sets); err != nil {
+		return nil, err
+	}
+	res := &Result{Records: exportedRecords(records), Changes: len(changes)}
+	return res.finish(output.Bytes(), diag), nil
+}
+
+// Export writes a zone file from the aws_route53_record resources of a
+// Terraform state, or of terraform show -json for a state or plan. The
+// records are selected by the hosted zone ID, which when empty is taken from
+// the aws_route53_zone named after the domain.
+func (c *Converter) Export(state io.Reader, hostedZoneID string) (*Result, error) {
+	diag := c.newDiagnostics()
+	var output bytes.Buffer
+	if err := exportZone(state, c.opts.Domain, hostedZoneID, &output, diag); err != nil {
+		return nil, err
+	}
+	return (&Result{}).finish(output.Bytes(), diag), nil
+}
+
+// ApplyOptions configure Apply.
+type ApplyOptions struct {
+	// HostedZoneID is the hosted zone to apply to. Defaults to the hosted
+	// zone named after the domain.
+	HostedZoneID string
+	// Endpoint is the Route 53 API endpoint. Defaults to the endpoint of
+	// the AWS configuration, such as AWS_ENDPOINT_URL_ROUTE_53, or the AWS
+	// endpoint.
+	Endpoint string
+
+	// Out receives the planned changes and the progress, and In the
+	// confirmation, unless AutoApprove is set.
+	In          io.Reader
+	Out         io.Writer
+	AutoApprove bool
+}
+
+// Apply makes the record sets of a Route 53 hosted zone match the zone file.
+// The changes are shown as a plan and only submitted once confirmed, and
+// Apply returns once Route 53 has propagated them. Nothing is planned or
+// submitted when the zone file has errors, as the record sets left out would
+// be deleted from the hosted zone.
+func (c *Converter) Apply(r io.Reader, opts ApplyOptions) (*Result, error) {
+	diag := c.newDiagnostics()
+	records := readZoneRecords(r, c.opts.FileName, c.opts.Domain, c.excludedTypes, c.ttlPolicy, diag)
+	validateRoute53(records, diag)
+	res := &Result{Records: exportedRecords(records)}
+	if diag.hasErrors() {
+		diag.errorf("Not applying to the hosted zone, as the zone file has errors")
+		return res.finish(nil, diag), nil
+	}
+
+	client, err := newRoute53Client(opts.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	a := newApplier(client, opts.In, opts.Out, opts.AutoApprove, diag)
+	if err := a.apply(c.opts.Domain, opts.HostedZoneID, records, c.excludedTypes); err != nil {
+		return nil, err
+	}
+	return res.finish(nil, diag), nil
+}
+
+// LintOptions configure Lint.
+type LintOptions struct {
+	// Format is the format of the report: text, json or sarif. Defaults to
+	// text.
+	Format string
+	// Rules sets the severity of rules by their ID, as info, warning or
+	// error, or turns them off with off. Other rules keep their default.
+	Rules map[string]string
+	// MinTTL is the lowest TTL the low-ttl rule accepts. Defaults to 60.
+	MinTTL uint32
+	// Siblings are the other zones of a batch, which targets outside the
+	// zone are resolved in before they are reported as dangling.
+	Siblings []SiblingZone
+	// TakeoverSuffixes adds suffixes of services prone to subdomain
+	// takeover to the built-in list, by the name of the service. A label
+	// may be a pattern, such as s3-website-*.amazonaws.com.
+	TakeoverSuffixes map[string]string
+}
+
+// SiblingZone is a zone file of a batch, which is read but not checked.
+type SiblingZone struct {
+	Domain   string
+	FileName string
+	Zone     io.Reader
+}
+
+// Lint checks the record sets of the zone file with the lint rules,
+// reporting the findings in the configured format. All records are checked,
+// including those of excluded types, and the aliases written as comments by
+// Export.
+func (c *Converter) Lint(r io.Reader, opts LintOptions) (*Result, error) {
+	if opts.Format == "" {
+		opts.Format = "text"
+	}
+	if opts.MinTTL == 0 {
+		opts.MinTTL = defaultLintMinTTL
+	}
+	severities, err := lintRuleSeverities(opts.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	fileName := zoneFileName(r, c.opts.FileName)
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	diag := c.newDiagnostics()
+	records := readZoneRecords(bytes.NewReader(src), fileName, c.opts.Domain, nil, c.ttlPolicy, diag)
+	z := newLintZone(c.opts.Domain, opts.MinTTL, records)
+	z.aliases = aliasComments(src, fileName)
+	z.takeover = takeoverSuffixList(opts.TakeoverSuffixes)
+	for _, sibling := range opts.Siblings {
+		siblingRecords := readZoneRecords(sibling.Zone, sibling.FileName, sibling.Domain, nil, c.ttlPolicy, diag)
+		z.siblings = append(z.siblings, newLintZone(sibling.Domain, opts.MinTTL, siblingRecords))
+	}
+	findings := lintZoneRecords(z, severities, c.opts.Strict)
+
+	var output bytes.Buffer
+	if err := writeLint(&output, opts.Format, findings, severities); err != nil {
+		return nil, err
+	}
+	res := &Result{Records: exportedRecords(records), Findings: findings}
+	return res.finish(output.Bytes(), diag), nil
+}
+
+// targetsRoute53 returns whether Convert generates record sets for Route 53
+// only, so that they are validated against its limits. Mirrored conversions
+// report unsupported records in the mirror report instead.
+func (c *Converter) targetsRoute53() bool {
+	if c.opts.Format != "terraform" {
+		return true
+	}
+	return c.opts.Provider == "route53" && c.opts.Mirror == ""
+}
+
+// zoneWriter returns the writer of the configured output format other than
+// Terraform.
+func (c *Converter) zoneWriter() (zoneWriter, error) {
+	switch c.opts.Format {
+	case "cloudformation-yaml":
+		return &cloudFormationWriter{recordSetGroups: c.opts.RecordSetGroups, naming: c.opts.Naming}, nil
+	case "cloudformation-json":
+		return &cloudFormationWriter{json: true, recordSetGroups: c.opts.RecordSetGroups, naming: c.opts.Naming}, nil
+	case "pulumi-yaml":
+		return &pulumiWriter{existingZone: c.opts.ExistingZone, naming: c.opts.Naming}, nil
+	case "route53-changebatch":
+		return &changeBatchWriter{}, nil
+	default:
+		return nil, fmt.Errorf("Unknown output format %q", c.opts.Format)
+	}
+}
+
+// configuredProvider returns a new target of the named provider, configured
+// from the provider specific options.
+func (c *Converter) configuredProvider(name string) (providerTarget, error) {
+	provider, err := providerFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	if azure, ok := provider.(*azureTarget); ok {
+		if c.opts.AzureResourceGroup == "" {
+			return nil, fmt.Errorf("Resource group is required for the azure provider")
+		}
+		azure.resourceGroup = c.opts.AzureResourceGroup
+	}
+	if c.opts.ExistingZone {
+		route53, ok := provider.(*route53Target)
+		if !ok {
+			return nil, fmt.Errorf("Existing zones are not supported by the %s provider", provider)
+		}
+		route53.existingZone = true
+	}
+	return provider, nil
+}
+
+// newDiagnostics returns the collector of the diagnostics of a call.
+func (c *Converter) newDiagnostics() *diagnostics {
+	return &diagnostics{strict: c.opts.Strict}
+}
+
+func (res *Result) finish(output []byte, diag *diagnostics) *Result {
+	res.Output = output
+	res.Diagnostics = diag.list
+	return res
+}
+
+// exportedRecords returns the record sets sorted by name and type.
+func exportedRecords(records map[recordKey]dnsRecord) []Record {
+	keys := make(recordKeySlice, 0, len(records))
+	for key := range records {
+		keys = append(keys, key)
+	}
+	sort.Sort(keys)
+
+	exported := make([]Record, len(keys))
+	for i, key := range keys {
+		rec := records[key]
+		exported[i] = Record{
+			Name:     rec.Name,
+			Type:     rec.Type,
+			TTL:      rec.TTL,
+			Data:     rec.Data,
+			Comments: rec.Comments,
+			Line:     rec.Line,
+		}
+	}
+	return exported
+}
@@ -0,0 +1,2262 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/google/go-cmp/cmp"
+	"github.com/miekg/dns"
+)
+
+var (
+	diffOpts = cmp.Options{
+		cmp.Transformer("ignoreSurroundingWhitespace", func(in string) string {
+			return strings.TrimSpace(in)
+		}),
+	}
+)
+
+func caseName(name string, syntax Syntax) string {
+	return fmt.Sprintf("%s-%v", name, syntax)
+}
+
+func TestGenerateRecordResource(t *testing.T) {
+	record := dnsRecord{
+		Name:     "foo.bar",
+		Data:     []string{"127.0.0.1"},
+		Type:     "A",
+		TTL:      3600,
+		Comments: []string{"This is a test"},
+	}
+
+	cases := []struct {
+		name         string
+		existingZone bool
+		expected     map[Syntax]string
+	}{
+		{
+			name: "basic",
+			expected: map[Syntax]string{
+				Modern: `# This is a test
+resource "aws_route53_record" "foo-bar-A" {
+  zone_id = aws_route53_zone.test-zone.zone_id
+  name    = "foo.bar"
+  type    = "A"
+  ttl     = "3600"
+  records = ["127.0.0.1"]
+}`,
+				Legacy: `# This is a test
+resource "aws_route53_record" "foo-bar-A" {
+  zone_id = "${aws_route53_zone.test-zone.zone_id}"
+  name    = "foo.bar"
+  type    = "A"
+  ttl     = "3600"
+  records = ["127.0.0.1"]
+}`,
+			},
+		},
+		{
+			name:         "existing zone",
+			existingZone: true,
+			expected: map[Syntax]string{
+				Modern: `# This is a test
+resource "aws_route53_record" "foo-bar-A" {
+  zone_id = data.aws_route53_zone.test-zone.zone_id
+  name    = "foo.bar"
+  type    = "A"
+  ttl     = "3600"
+  records = ["127.0.0.1"]
+}`,
+				Legacy: `# This is a test
+resource "aws_route53_record" "foo-bar-A" {
+  zone_id = "${data.aws_route53_zone.test-zone.zone_id}"
+  name    = "foo.bar"
+  type    = "A"
+  ttl     = "3600"
+  records = ["127.0.0.1"]
+}`,
+			},
+		},
+	}
+	for _, tc := range cases {
+		for _, legacySyntax := range []Syntax{Modern, Legacy} {
+			t.Run(caseName(tc.name, legacySyntax), func(t *testing.T) {
+				g := newConfigGenerator(&route53Target{existingZone: tc.existingZone}, legacySyntax, &diagnostics{})
+
+				var buf bytes.Buffer
+				err := g.generateRecordResource(record, zoneTemplateData{ID: "test-zone", Domain: "bar"}, &buf)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if diff := cmp.Diff(tc.expected[legacySyntax], buf.String(), diffOpts); diff != "" {
+					t.Errorf("Unexpected result from resource generation (-want +got):\n%s", diff)
+				}
+			})
+		}
+	}
+}
+
+func TestGenerateGoogleRecordResource(t *testing.T) {
+	cases := []struct {
+		name     string
+		record   dnsRecord
+		expected map[Syntax]string
+	}{
+		{
+			name: "basic",
+			record: dnsRecord{
+				Name: "foo.bar.",
+				Data: []string{"127.0.0.1"},
+				Type: "A",
+				TTL:  3600,
+			},
+			expected: map[Syntax]string{
+				Modern: `resource "google_dns_record_set" "foo-bar-A" {
+  managed_zone = google_dns_managed_zone.test-zone.name
+  name         = "foo.bar."
+  type         = "A"
+  ttl          = 3600
+  rrdatas      = ["127.0.0.1"]
+}`,
+				Legacy: `resource "google_dns_record_set" "foo-bar-A" {
+  managed_zone = "${google_dns_managed_zone.test-zone.name}"
+  name         = "foo.bar."
+  type         = "A"
+  ttl          = 3600
+  rrdatas      = ["127.0.0.1"]
+}`,
+			},
+		},
+		{
+			name: "txt",
+			record: dnsRecord{
+				Name: "foo.bar.",
+				Data: []string{`"v=spf1 -all"`, `"first" "second"`},
+				Type: "TXT",
+				TTL:  300,
+			},
+			expected: map[Syntax]string{
+				Modern: `resource "google_dns_record_set" "foo-bar-TXT" {
+  managed_zone = google_dns_managed_zone.test-zone.name
+  name         = "foo.bar."
+  type         = "TXT"
+  ttl          = 300
+  rrdatas      = ["\"v=spf1 -all\"", "\"first\" \"second\""]
+}`,
+				Legacy: `resource "google_dns_record_set" "foo-bar-TXT" {
+  managed_zone = "${google_dns_managed_zone.test-zone.name}"
+  name         = "foo.bar."
+  type         = "TXT"
+  ttl          = 300
+  rrdatas      = ["\"v=spf1 -all\"", "\"first\" \"second\""]
+}`,
+			},
+		},
+	}
+	for _, tc := range cases {
+		for _, syntax := range []Syntax{Modern, Legacy} {
+			t.Run(caseName(tc.name, syntax), func(t *testing.T) {
+				g := newConfigGenerator(&googleTarget{}, syntax, &diagnostics{})
+
+				var buf bytes.Buffer
+				err := g.generateRecordResource(tc.record, zoneTemplateData{ID: "test-zone", Domain: "bar"}, &buf)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if diff := cmp.Diff(tc.expected[syntax], buf.String(), diffOpts); diff != "" {
+					t.Errorf("Unexpected result from resource generation (-want +got):\n%s", diff)
+				}
+			})
+		}
+	}
+}
+
+func TestGoogleZoneName(t *testing.T) {
+	cases := []struct {
+		id             string
+		expectedOutput string
+	}{
+		{"example-com", "example-com"},
+		{"Example-COM", "example-com"},
+		{"123-example-com", "zone-123-example-com"},
+		{"xn--bcher-kva-example", "xn--bcher-kva-example"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.id, func(t *testing.T) {
+			name := googleZoneName(c.id)
+			if name != c.expectedOutput {
+				t.Errorf("Expected %q, got %q", c.expectedOutput, name)
+			}
+		})
+	}
+}
+
+func TestGenerateAzureRecordResource(t *testing.T) {
+	cases := []struct {
+		name     string
+		record   dnsRecord
+		expected string
+	}{
+		{
+			name: "list",
+			record: dnsRecord{
+				Name: "foo.bar.",
+				Data: []string{"127.0.0.1", "127.0.0.2"},
+				Type: "A",
+				TTL:  3600,
+			},
+			expected: `resource "azurerm_dns_a_record" "foo-bar-A" {
+  name                = "foo"
+  zone_name           = azurerm_dns_zone.test-zone.name
+  resource_group_name = azurerm_dns_zone.test-zone.resource_group_name
+  ttl                 = 3600
+  records             = ["127.0.0.1", "127.0.0.2"]
+}`,
+		},
+		{
+			name: "cname",
+			record: dnsRecord{
+				Name: "foo.bar.",
+				Data: []string{"baz.bar."},
+				Type: "CNAME",
+				TTL:  3600,
+			},
+			expected: `resource "azurerm_dns_cname_record" "foo-bar-CNAME" {
+  name                = "foo"
+  zone_name           = azurerm_dns_zone.test-zone.name
+  resource_group_name = azurerm_dns_zone.test-zone.resource_group_name
+  ttl                 = 3600
+  record              = "baz.bar."
+}`,
+		},
+		{
+			name: "srv",
+			record: dnsRecord{
+				Name: "_sip._tcp.bar.",
+				Data: []string{"10 60 5060 sip.bar."},
+				Type: "SRV",
+				TTL:  300,
+			},
+			expected: `resource "azurerm_dns_srv_record" "_sip-_tcp-bar-SRV" {
+  name                = "_sip._tcp"
+  zone_name           = azurerm_dns_zone.test-zone.name
+  resource_group_name = azurerm_dns_zone.test-zone.resource_group_name
+  ttl                 = 300
+
+  record {
+    priority = 10
+    weight   = 60
+    port     = 5060
+    target   = "sip.bar."
+  }
+}`,
+		},
+		{
+			name: "caa",
+			record: dnsRecord{
+				Name: "bar.",
+				Data: []string{`0 issue "letsencrypt.org"`},
+				Type: "CAA",
+				TTL:  300,
+			},
+			expected: `resource "azurerm_dns_caa_record" "bar-CAA" {
+  name                = "@"
+  zone_name           = azurerm_dns_zone.test-zone.name
+  resource_group_name = azurerm_dns_zone.test-zone.resource_group_name
+  ttl                 = 300
+
+  record {
+    flags = 0
+    tag   = "issue"
+    value = "letsencrypt.org"
+  }
+}`,
+		},
+		{
+			name: "txt",
+			record: dnsRecord{
+				Name: "bar.",
+				Data: []string{`"first" "second"`},
+				Type: "TXT",
+				TTL:  300,
+			},
+			expected: `resource "azurerm_dns_txt_record" "bar-TXT" {
+  name                = "@"
+  zone_name           = azurerm_dns_zone.test-zone.name
+  resource_group_name = azurerm_dns_zone.test-zone.resource_group_name
+  ttl                 = 300
+
+  record {
+    value = "firstsecond"
+  }
+}`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newConfigGenerator(&azureTarget{}, Modern, &diagnostics{})
+
+			var buf bytes.Buffer
+			err := g.generateRecordResource(tc.record, zoneTemplateData{ID: "test-zone", Domain: "bar"}, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tc.expected, buf.String(), diffOpts); diff != "" {
+				t.Errorf("Unexpected result from resource generation (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGenerateCloudflareRecordResources(t *testing.T) {
+	cases := []struct {
+		name     string
+		record   dnsRecord
+		expected string
+	}{
+		{
+			name: "proxied",
+			record: dnsRecord{
+				Name:        "foo.bar.",
+				Data:        []string{"127.0.0.1", "127.0.0.2"},
+				Type:        "A",
+				TTL:         3600,
+				Comments:    []string{" tfz53:proxied"},
+				Annotations: []string{"tfz53:proxied"},
+			},
+			expected: `#  tfz53:proxied
+resource "cloudflare_record" "foo-bar-A-4b84b15b" {
+  zone_id = cloudflare_zone.test-zone.id
+  name    = "foo"
+  type    = "A"
+  value   = "127.0.0.1"
+  ttl     = 1
+  proxied = true
+}
+
+resource "cloudflare_record" "foo-bar-A-ec254bc5" {
+  zone_id = cloudflare_zone.test-zone.id
+  name    = "foo"
+  type    = "A"
+  value   = "127.0.0.2"
+  ttl     = 1
+  proxied = true
+}`,
+		},
+		{
+			name: "mx",
+			record: dnsRecord{
+				Name: "bar.",
+				Data: []string{"10 mail.bar."},
+				Type: "MX",
+				TTL:  300,
+			},
+			expected: `resource "cloudflare_record" "bar-MX-b934d958" {
+  zone_id  = cloudflare_zone.test-zone.id
+  name     = "@"
+  type     = "MX"
+  value    = "mail.bar"
+  priority = 10
+  ttl      = 300
+  proxied  = false
+}`,
+		},
+		{
+			name: "srv",
+			record: dnsRecord{
+				Name: "_sip._tcp.bar.",
+				Data: []string{"10 60 5060 sip.bar."},
+				Type: "SRV",
+				TTL:  300,
+			},
+			expected: `resource "cloudflare_record" "_sip-_tcp-bar-SRV-1641ccd5" {
+  zone_id = cloudflare_zone.test-zone.id
+  name    = "_sip._tcp"
+  type    = "SRV"
+  ttl     = 300
+  proxied = false
+
+  data {
+    service  = "_sip"
+    proto    = "_tcp"
+    name     = "bar"
+    priority = 10
+    weight   = 60
+    port     = 5060
+    target   = "sip.bar"
+  }
+}`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newConfigGenerator(&cloudflareTarget{}, Modern, &diagnostics{})
+
+			var buf bytes.Buffer
+			err := g.generateRecordResource(tc.record, zoneTemplateData{ID: "test-zone", Domain: "bar"}, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tc.expected, buf.String(), diffOpts); diff != "" {
+				t.Errorf("Unexpected result from resource generation (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCloudflareProxiedRecordSet(t *testing.T) {
+	// The annotation on one line proxies every value of the record set
+	zone := `$ORIGIN bar.
+www 300 IN A 192.0.2.1 ; tfz53:proxied
+www 300 IN A 192.0.2.2
+mail 300 IN A 192.0.2.3
+`
+	c, err := New(Options{Domain: "bar", Provider: "cloudflare", Exclude: []string{"SOA", "NS"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.Convert(strings.NewReader(zone))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxied := regexp.MustCompile(`(?s)value   = "([0-9.]+)".*?proxied = (true|false)`).FindAllStringSubmatch(string(res.Output), -1)
+	got := make(map[string]string)
+	for _, m := range proxied {
+		got[m[1]] = m[2]
+	}
+	expected := map[string]string{"192.0.2.1": "true", "192.0.2.2": "true", "192.0.2.3": "false"}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("Unexpected proxied values (-want +got):\n%s\n%s", diff, res.Output)
+	}
+}
+
+func TestGenerateDigitalOceanRecordResources(t *testing.T) {
+	cases := []struct {
+		name      string
+		record    dnsRecord
+		expected  string
+		expectErr bool
+	}{
+		{
+			name: "minimum-ttl",
+			record: dnsRecord{
+				Name: "foo.bar.",
+				Data: []string{"127.0.0.1"},
+				Type: "A",
+				TTL:  5,
+			},
+			expected: `resource "digitalocean_record" "foo-bar-A-4b84b15b" {
+  domain = digitalocean_domain.test-zone.id
+  type   = "A"
+  name   = "foo"
+  value  = "127.0.0.1"
+  ttl    = 30
+}`,
+		},
+		{
+			name: "srv",
+			record: dnsRecord{
+				Name: "_sip._tcp.bar.",
+				Data: []string{"10 60 5060 sip.bar."},
+				Type: "SRV",
+				TTL:  300,
+			},
+			expected: `resource "digitalocean_record" "_sip-_tcp-bar-SRV-1641ccd5" {
+  domain   = digitalocean_domain.test-zone.id
+  type     = "SRV"
+  name     = "_sip._tcp"
+  value    = "sip.bar."
+  priority = 10
+  weight   = 60
+  port     = 5060
+  ttl      = 300
+}`,
+		},
+		{
+			name: "unsupported-caa-tag",
+			record: dnsRecord{
+				Name: "bar.",
+				Data: []string{`0 issue "letsencrypt.org"`, `0 contactemail "hostmaster@bar"`},
+				Type: "CAA",
+				TTL:  300,
+			},
+			expected: `resource "digitalocean_record" "bar-CAA-2f06b12e" {
+  domain = digitalocean_domain.test-zone.id
+  type   = "CAA"
+  name   = "@"
+  value  = "letsencrypt.org"
+  flags  = 0
+  tag    = "issue"
+  ttl    = 300
+}`,
+			expectErr: true,
+		},
+		{
+			name: "apex-cname",
+			record: dnsRecord{
+				Name: "bar.",
+				Data: []string{"baz."},
+				Type: "CNAME",
+				TTL:  300,
+			},
+			expected:  "",
+			expectErr: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newConfigGenerator(&digitalOceanTarget{}, Modern, &diagnostics{})
+
+			var buf bytes.Buffer
+			err := g.generateRecordResource(tc.record, zoneTemplateData{ID: "test-zone", Domain: "bar"}, &buf)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("Unexpected error result: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.expected, buf.String(), diffOpts); diff != "" {
+				t.Errorf("Unexpected result from resource generation (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMirroredGeneration(t *testing.T) {
+	zone := `$ORIGIN bar.
+$TTL 300
+@    3600 IN NS  ns1.bar.
+@         IN TXT "hello world"
+loc       IN LOC 52 22 23.000 N 4 53 32.000 E -2.00m 0.00m 10000m 10m
+www       IN A   192.0.2.1
+`
+	m, err := newMirroredGenerator(&route53Target{}, &googleTarget{}, Modern, &diagnostics{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out, report bytes.Buffer
+	records := readZoneRecords(strings.NewReader(zone), "", "bar", excludedTypesFromString("SOA"), ttlFirst, &diagnostics{})
+	if err := m.generateTerraformForZone("bar", records, &out, &report); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedReport := `Mirror consistency report for route53 and google
+loc.bar. LOC: rejected, route53 does not support LOC records
+loc.bar. LOC: rejected, google does not support LOC records
+bar. TXT: google: character-strings are quoted, since Cloud DNS splits unquoted data on spaces
+bar. NS: replaced by the combined name servers of both providers
+`
+	if diff := cmp.Diff(expectedReport, report.String()); diff != "" {
+		t.Errorf("Unexpected consistency report (-want +got):\n%s", diff)
+	}
+
+	for _, expected := range []string{
+		`resource "aws_route53_record" "www-bar-A"`,
+		`resource "google_dns_record_set" "www-bar-A"`,
+		`resource "aws_route53_record" "bar-NS" {
+  allow_overwrite = true
+  zone_id         = aws_route53_zone.bar.zone_id
+  name            = "bar."
+  type            = "NS"
+  ttl             = "3600"
+  records         = concat(formatlist("%s.", aws_route53_zone.bar.name_servers), google_dns_managed_zone.bar.name_servers)
+}`,
+		`resource "google_dns_record_set" "bar-NS" {
+  managed_zone = google_dns_managed_zone.bar.name
+  name         = "bar."
+  type         = "NS"
+  ttl          = 3600
+  rrdatas      = concat(formatlist("%s.", aws_route53_zone.bar.name_servers), google_dns_managed_zone.bar.name_servers)
+}`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected output to contain %s, got:\n%s", expected, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "LOC") {
+		t.Errorf("Expected LOC record to be rejected on both sides, got:\n%s", out.String())
+	}
+}
+
+func TestMirroredGenerationAzure(t *testing.T) {
+	zone := `$ORIGIN bar.
+$TTL 300
+@    3600 IN NS  ns1.bar.
+www       IN A   192.0.2.1
+`
+	for _, withApexNS := range []bool{true, false} {
+		m, err := newMirroredGenerator(&route53Target{}, &azureTarget{resourceGroup: "dns"}, Modern, &diagnostics{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		src := zone
+		if !withApexNS {
+			src = strings.Replace(zone, "@    3600 IN NS  ns1.bar.\n", "", 1)
+		}
+		var out, report bytes.Buffer
+		records := readZoneRecords(strings.NewReader(src), "", "bar", excludedTypesFromString("SOA"), ttlFirst, &diagnostics{})
+		if err := m.generateTerraformForZone("bar", records, &out, &report); err != nil {
+			t.Fatal(err)
+		}
+
+		// Azure DNS creates the apex NS record set with the zone, which
+		// cannot be created over
+		expectedReport := `Mirror consistency report for route53 and azure
+bar. NS: route53: replaced by the combined name servers of both providers
+bar. NS: azure: left as created with the zone, listing only its own name servers, as it cannot be replaced
+`
+		if diff := cmp.Diff(expectedReport, report.String()); diff != "" {
+			t.Errorf("Unexpected consistency report (-want +got):\n%s", diff)
+		}
+		if !strings.Contains(out.String(), `resource "aws_route53_record" "bar-NS" {
+  allow_overwrite = true`) {
+			t.Errorf("Expected the Route 53 apex NS record set to be replaced, got:\n%s", out.String())
+		}
+		if strings.Contains(out.String(), "azurerm_dns_ns_record") {
+			t.Errorf("Expected no Azure apex NS record set, got:\n%s", out.String())
+		}
+	}
+}
+
+func TestMirrorRequiresNameServers(t *testing.T) {
+	if _, err := newMirroredGenerator(&route53Target{}, &cloudflareTarget{}, Modern, &diagnostics{}); err == nil {
+		t.Error("Expected error mirroring to a provider without apex NS support")
+	}
+	if _, err := newMirroredGenerator(&route53Target{}, &route53Target{}, Modern, &diagnostics{}); err == nil {
+		t.Error("Expected error mirroring a provider to itself")
+	}
+}
+
+func TestRelativeName(t *testing.T) {
+	cases := []struct {
+		name           string
+		expectedOutput string
+	}{
+		{"example.com.", "@"},
+		{"www.example.com.", "www"},
+		{"a.b.Example.com.", "a.b"},
+		{"example.org.", "example.org."},
+		{"notexample.com.", "notexample.com."},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			name := relativeName(c.name, "example.com")
+			if name != c.expectedOutput {
+				t.Errorf("Expected %q, got %q", c.expectedOutput, name)
+			}
+		})
+	}
+}
+
+func TestResourceNameSanitation(t *testing.T) {
+	cases := []struct {
+		name           string
+		expectedOutput string
+	}{
+		{"foo.bar.com", "foo-bar-com"},
+		{"*.bar.com", "wildcard-bar-com"},
+		{"åäö.bar.com", "xn---bar-com-zzaj2q"},
+		{"#issue-2.github.com", "_issue-2-github-com"},
+		{"//issue-2.github.com", "__issue-2-github-com"},
+		{"12-issue-12.github.com", "_12-issue-12-github-com"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			id := sanitizeRecordName(c.name)
+			if id != c.expectedOutput {
+				t.Errorf("Expected %q, got %q", c.expectedOutput, id)
+			}
+		})
+	}
+}
+
+func TestResourceNamer(t *testing.T) {
+	keys := []recordKey{
+		{"example.com.", "A"},
+		{"www.example.com.", "CNAME"},
+		{"a.b.example.com.", "A"},
+		{"a-b.example.com.", "A"},
+	}
+	cases := []struct {
+		strategy string
+		expected []string
+	}{
+		{"fqdn", []string{"example-com-A", "www-example-com-CNAME", "a-b-example-com-A-1d6600fe", "a-b-example-com-A-8c290112"}},
+		{"relative", []string{"apex-A", "www-CNAME", "a-b-A-1d6600fe", "a-b-A-8c290112"}},
+		{"hash", []string{"A-0e2cba66", "CNAME-3636b495", "A-1d6600fe", "A-8c290112"}},
+		{"{{.Relative}}_{{.Type | lower}}", []string{"apex_a", "www_cname", "a-b_a-1d6600fe", "a-b_a-8c290112"}},
+	}
+	for _, c := range cases {
+		t.Run(c.strategy, func(t *testing.T) {
+			n, err := newResourceNamer(c.strategy, "example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := n.assign(keys, &diagnostics{}); err != nil {
+				t.Fatal(err)
+			}
+			names := make([]string, len(keys))
+			for i, key := range keys {
+				names[i] = n.name(key)
+				if !n.generatedName(key, names[i]) {
+					t.Errorf("Name %s of %s %s not recognized as generated", names[i], key.Name, key.Type)
+				}
+			}
+			if diff := cmp.Diff(c.expected, names); diff != "" {
+				t.Errorf("Unexpected resource names (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := newResourceNamer("bogus", "example.com"); err == nil {
+		t.Error("Expected an unknown strategy to be rejected")
+	}
+}
+
+func TestAcceptance(t *testing.T) {
+	fileNames, err := filepath.Glob("testdata/*.zone")
+	if err != nil {
+		panic(err)
+	}
+
+	for _, n := range fileNames {
+		for _, providerName := range providerNames() {
+			for _, syntax := range []Syntax{Modern, Legacy} {
+				t.Run(caseName(fmt.Sprintf("%s-%s", n, providerName), syntax), func(t *testing.T) {
+					file, err := os.Open(n)
+					if err != nil {
+						panic(err)
+					}
+					expected, err := ioutil.ReadFile(strings.Replace(n, ".zone", fmt.Sprintf(".expected-%s-%v", providerName, syntax), 1))
+					if err != nil {
+						t.Fatalf("Missing golden file for provider %s: %v", providerName, err)
+					}
+
+					c, err := New(Options{
+						Domain:             strings.Replace(filepath.Base(n), ".zone", "", 1),
+						Exclude:            []string{"SOA", "NS"},
+						Syntax:             syntax,
+						Provider:           providerName,
+						AzureResourceGroup: "dns",
+					})
+					if err != nil {
+						t.Fatal(err)
+					}
+					res, err := c.Convert(file)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if diff := cmp.Diff(string(expected), string(res.Output), diffOpts); diff != "" {
+						t.Errorf("Unexpected result from full Terraform output (-want +got):\n%s", diff)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestConverterConcurrency(t *testing.T) {
+	zone := `$ORIGIN bar.
+$TTL 300
+www     IN A     192.0.2.1
+www     IN A     192.0.2.1
+a.b     IN A     192.0.2.2
+a-b     IN A     192.0.2.3
+broken  IN A     not-an-address
+`
+	c, err := New(Options{Domain: "bar", Provider: "cloudflare"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := c.Convert(strings.NewReader(zone))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected.Count(Warning) != 2 || expected.Count(Error) != 1 {
+		t.Fatalf("Expected 2 warnings and 1 error, got %v", expected.Diagnostics)
+	}
+
+	results := make(chan *Result)
+	for i := 0; i < 8; i++ {
+		go func() {
+			res, err := c.Convert(strings.NewReader(zone))
+			if err != nil {
+				t.Error(err)
+			}
+			results <- res
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		if diff := cmp.Diff(expected, <-results); diff != "" {
+			t.Errorf("Unexpected result from concurrent conversion (-want +got):\n%s", diff)
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	zone := `$ORIGIN bar.
+$TTL 300
+@       IN SOA   ns hostmaster (
+                 1 7200 3600 1209600 300 ) ; serial
+; www
+www     IN A     192.0.2.1
+        IN A     192.0.2.2
+www 60  IN A     192.0.2.3
+txt     IN TXT   "a;b" "("
+broken  IN A     not-an-address
+after   IN A     192.0.2.4
+`
+	for _, strict := range []bool{false, true} {
+		t.Run(fmt.Sprintf("strict=%t", strict), func(t *testing.T) {
+			c, err := New(Options{Domain: "bar", FileName: "bar.zone", Exclude: []string{"SOA"}, Strict: strict})
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := c.Convert(strings.NewReader(zone))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ttlSeverity := Warning
+			if strict {
+				ttlSeverity = Error
+			}
+			expected := []string{
+				"bar.zone:3: info: Excluding bar. SOA",
+				fmt.Sprintf("bar.zone:8: %s: TTL 60 of www.bar. A conflicts with TTL 300 at line 6, using 300", ttlSeverity),
+				`bar.zone:10: error: bad A A: "not-an-address" at column 31, the rest of the file is skipped`,
+			}
+			var got []string
+			for _, d := range res.Diagnostics {
+				got = append(got, d.String())
+			}
+			if diff := cmp.Diff(expected, got); diff != "" {
+				t.Errorf("Unexpected diagnostics (-want +got):\n%s", diff)
+			}
+
+			lines := make(map[string]int)
+			for _, rec := range res.Records {
+				lines[rec.Name] = rec.Line
+			}
+			if diff := cmp.Diff(map[string]int{"txt.bar.": 9, "www.bar.": 6}, lines); diff != "" {
+				t.Errorf("Unexpected record lines (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTTLPolicy(t *testing.T) {
+	zone := `$ORIGIN bar.
+www 300  IN A   192.0.2.1
+www 3600 IN A   192.0.2.2
+www 60   IN A   192.0.2.3
+www 300  IN A   192.0.2.1
+txt 300  IN TXT "a"
+`
+	testCases := map[string]struct {
+		ttl         uint32
+		diagnostics []string
+	}{
+		"first": {300, []string{
+			"bar.zone:3: warning: TTL 3600 of www.bar. A conflicts with TTL 300 at line 2, using 300",
+			"bar.zone:4: warning: TTL 60 of www.bar. A conflicts with TTL 300 at line 2, using 300",
+		}},
+		"last": {300, []string{
+			"bar.zone:3: warning: TTL 3600 of www.bar. A conflicts with TTL 300 at line 2, using 3600",
+			"bar.zone:4: warning: TTL 60 of www.bar. A conflicts with TTL 3600 at line 3, using 60",
+			"bar.zone:5: warning: TTL 300 of www.bar. A conflicts with TTL 60 at line 4, using 300",
+		}},
+		"min": {60, []string{
+			"bar.zone:3: warning: TTL 3600 of www.bar. A conflicts with TTL 300 at line 2, using 300",
+			"bar.zone:4: warning: TTL 60 of www.bar. A conflicts with TTL 300 at line 2, using 60",
+			"bar.zone:5: warning: TTL 300 of www.bar. A conflicts with TTL 60 at line 4, using 60",
+		}},
+		"max": {3600, []string{
+			"bar.zone:3: warning: TTL 3600 of www.bar. A conflicts with TTL 300 at line 2, using 3600",
+			"bar.zone:4: warning: TTL 60 of www.bar. A conflicts with TTL 3600 at line 3, using 3600",
+			"bar.zone:5: warning: TTL 300 of www.bar. A conflicts with TTL 3600 at line 3, using 3600",
+		}},
+		"fail": {0, []string{
+			"bar.zone:3: error: TTL 3600 of www.bar. A conflicts with TTL 300 at line 2, skipping the record set",
+			"bar.zone:4: error: TTL 60 of www.bar. A conflicts with TTL 300 at line 2, skipping the record set",
+		}},
+	}
+	for policy, tc := range testCases {
+		t.Run(policy, func(t *testing.T) {
+			c, err := New(Options{Domain: "bar", FileName: "bar.zone", TTLPolicy: policy})
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := c.Convert(strings.NewReader(zone))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := append(tc.diagnostics, "bar.zone:5: warning: Skipping duplicate A value 192.0.2.1 for www.bar.")
+			var got []string
+			for _, d := range res.Diagnostics {
+				got = append(got, d.String())
+			}
+			if diff := cmp.Diff(expected, got); diff != "" {
+				t.Errorf("Unexpected diagnostics (-want +got):\n%s", diff)
+			}
+
+			sets := make(map[string]Record)
+			for _, rec := range res.Records {
+				sets[rec.Name] = rec
+			}
+			www, ok := sets["www.bar."]
+			if tc.ttl == 0 {
+				if ok {
+					t.Errorf("Expected www.bar. to be left out, got %v", www)
+				}
+			} else if www.TTL != tc.ttl || len(www.Data) != 3 {
+				t.Errorf("Expected 3 values with TTL %d, got %v", tc.ttl, www)
+			}
+			if len(sets["txt.bar."].Data) != 1 {
+				t.Errorf("Expected txt.bar. to be kept, got %v", sets["txt.bar."])
+			}
+		})
+	}
+
+	if _, err := New(Options{Domain: "bar", TTLPolicy: "average"}); err == nil {
+		t.Error("Expected an error for an unknown TTL policy")
+	}
+}
+
+func TestRecordLines(t *testing.T) {
+	testCases := map[string]struct {
+		zone     string
+		expected []int
+	}{
+		"directives and comments": {"$ORIGIN bar.\n\n; a\n  ; b\nwww IN A 192.0.2.1\n\tIN A 192.0.2.2 ; c\n", []int{5, 6}},
+		"parentheses":             {"@ IN SOA ns hm (\n 1 2\n 3 4 5 )\nwww IN A 192.0.2.1", []int{1, 4}},
+		"quoted":                  {"a IN TXT \"(;\\\"\"\nb IN TXT \"x\"\n", []int{1, 2}},
+		"escaped newline":         {"a IN TXT x\\\ny\nb IN A 192.0.2.1\n", []int{1, 3}},
+		"include":                 {"www IN A 192.0.2.1\n$INCLUDE other.zone\n", nil},
+		"generate":                {"$generate 1-2 host$ A 192.0.2.$\n", nil},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.expected, recordLines([]byte(tc.zone))); diff != "" {
+				t.Errorf("Unexpected lines (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFormatAcceptance(t *testing.T) {
+	fileNames, err := filepath.Glob("testdata/*.zone")
+	if err != nil {
+		panic(err)
+	}
+
+	for _, n := range fileNames {
+		for _, format := range []string{"cloudformation-yaml", "cloudformation-json", "pulumi-yaml", "route53-changebatch"} {
+			t.Run(fmt.Sprintf("%s-%s", n, format), func(t *testing.T) {
+				file, err := os.Open(n)
+				if err != nil {
+					panic(err)
+				}
+				expected, err := ioutil.ReadFile(strings.Replace(n, ".zone", fmt.Sprintf(".expected-%s", format), 1))
+				if err != nil {
+					t.Fatalf("Missing golden file for format %s: %v", format, err)
+				}
+
+				c, err := New(Options{
+					Domain:  strings.Replace(filepath.Base(n), ".zone", "", 1),
+					Exclude: []string{"SOA", "NS"},
+					Format:  format,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				res, err := c.Convert(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if diff := cmp.Diff(string(expected), string(res.Output), diffOpts); diff != "" {
+					t.Errorf("Unexpected result from full %s output (-want +got):\n%s", format, diff)
+				}
+			})
+		}
+	}
+}
+
+func TestPulumiInterpolation(t *testing.T) {
+	zone := `$ORIGIN bar.
+txt 300 IN TXT "v=spf1 include:${domain} -all" "a$${b}"
+`
+	records := readZoneRecords(strings.NewReader(zone), "", "bar", excludedTypesFromString("SOA,NS"), ttlFirst, &diagnostics{})
+	var buf bytes.Buffer
+	w := &pulumiWriter{naming: defaultNamingStrategy}
+	if err := w.writeZone("bar", records, &buf, &diagnostics{}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := `v=spf1 include:$${domain} -all\"\"a$$${b}`; !strings.Contains(buf.String(), expected) {
+		t.Errorf("Expected the value to be escaped as %s, got:\n%s", expected, buf.String())
+	}
+}
+
+func TestChunkRecordSets(t *testing.T) {
+	records := make([]dnsRecord, 0, 600)
+	for i := 0; i < 600; i++ {
+		records = append(records, dnsRecord{
+			Name: fmt.Sprintf("host%d.bar.", i),
+			Type: "A",
+			Data: []string{"192.0.2.1"},
+		})
+	}
+
+	cases := []struct {
+		weight         int
+		expectedChunks []int
+	}{
+		// 1000 records per change, counting UPSERTs twice
+		{route53UpsertWeight, []int{500, 100}},
+		// 32000 value characters per change, with 9 characters per value
+		{1, []int{600}},
+	}
+	for _, tc := range cases {
+		t.Run(fmt.Sprintf("weight-%d", tc.weight), func(t *testing.T) {
+			var sizes []int
+			for _, chunk := range chunkRecordSets(records, tc.weight, &diagnostics{}) {
+				sizes = append(sizes, len(chunk))
+			}
+			if diff := cmp.Diff(tc.expectedChunks, sizes); diff != "" {
+				t.Errorf("Unexpected chunk sizes (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	long := []dnsRecord{
+		{Name: "a.bar.", Type: "TXT", Data: []string{strings.Repeat("x", 20000)}},
+		{Name: "b.bar.", Type: "TXT", Data: []string{strings.Repeat("x", 20000)}},
+	}
+	if n := len(chunkRecordSets(long, 1, &diagnostics{})); n != 2 {
+		t.Errorf("Expected values over the character limit to be split into 2 chunks, got %d", n)
+	}
+}
+
+func TestCloudFormationSplit(t *testing.T) {
+	records := make(map[recordKey]dnsRecord)
+	for i := 0; i < 1500; i++ {
+		rec := dnsRecord{
+			Name: fmt.Sprintf("host%d.bar.", i),
+			Type: "TXT",
+			TTL:  300,
+			Data: []string{fmt.Sprintf(`"%s"`, strings.Repeat("x", 40))},
+		}
+		records[recordKey{rec.Name, rec.Type}] = rec
+	}
+
+	for _, asJSON := range []bool{false, true} {
+		for _, groups := range []bool{false, true} {
+			t.Run(fmt.Sprintf("json-%v-groups-%v", asJSON, groups), func(t *testing.T) {
+				var buf bytes.Buffer
+				diag := &diagnostics{}
+				w := &cloudFormationWriter{json: asJSON, recordSetGroups: groups, naming: "fqdn"}
+				if err := w.writeZone("bar", records, &buf, diag); err != nil {
+					t.Fatal(err)
+				}
+				if asJSON {
+					// Several templates cannot be written as a single JSON
+					// document
+					if !diag.hasErrors() {
+						t.Error("Expected an error writing several templates as JSON")
+					}
+					if buf.Len() > 0 {
+						t.Errorf("Expected no output, got %d bytes", buf.Len())
+					}
+					return
+				}
+
+				for _, d := range diag.list {
+					if d.Severity != Info {
+						t.Errorf("Unexpected diagnostic %s", d)
+					}
+				}
+				zoneType := "AWS::Route53::HostedZone\n"
+				templates := strings.Split(buf.String(), "---\n")
+				if len(templates) < 2 {
+					t.Fatalf("Expected the zone to be split over several templates, got %d", len(templates))
+				}
+
+				recordSets := 0
+				for i, template := range templates {
+					if len(template) > cloudFormationMaxBodySize {
+						t.Errorf("Template %d is %d bytes, more than %d", i+1, len(template), cloudFormationMaxBodySize)
+					}
+					if declaresZone := strings.Contains(template, zoneType); declaresZone != (i == 0) {
+						t.Errorf("Expected only the first template to declare the hosted zone, template %d does: %v", i+1, declaresZone)
+					}
+					if hasParameter := strings.Contains(template, "AWS::Route53::HostedZone::Id"); hasParameter == (i == 0) {
+						t.Errorf("Expected only the templates after the first to take the hosted zone as a parameter, template %d does: %v", i+1, hasParameter)
+					}
+					recordSets += len(regexp.MustCompile(`host\d+\.bar\.`).FindAllString(template, -1))
+				}
+				if recordSets != len(records) {
+					t.Errorf("Expected %d record sets over all templates, got %d", len(records), recordSets)
+				}
+			})
+		}
+	}
+}
+
+func TestZoneDelta(t *testing.T) {
+	oldZone := `$ORIGIN bar.
+$TTL 1h
+@     IN A     192.0.2.1
+@     IN A     192.0.2.2
+www   IN CNAME bar.
+old   IN TXT   "gone"
+`
+	newZone := `$ORIGIN bar.
+$TTL 1h
+@     IN A     192.0.2.2
+@     IN A     192.0.2.1
+www 5m IN CNAME bar.
+new   IN TXT   "first" "second"
+`
+	excluded := excludedTypesFromString("SOA,NS")
+	changes := zoneDelta(readZoneRecords(strings.NewReader(oldZone), "", "bar", excluded, ttlFirst, &diagnostics{}), readZoneRecords(strings.NewReader(newZone), "", "bar", excluded, ttlFirst, &diagnostics{}))
+
+	var text bytes.Buffer
+	if err := writeDelta(&text, "text", "bar", changes); err != nil {
+		t.Fatal(err)
+	}
+	expectedText := `modified www.bar. CNAME
+  - www.bar. 3600 IN CNAME bar.
+  + www.bar. 300 IN CNAME bar.
+deleted old.bar. TXT
+  - old.bar. 3600 IN TXT "gone"
+created new.bar. TXT
+  + new.bar. 3600 IN TXT "first" "second"
+1 created, 1 deleted, 1 modified
+`
+	if diff := cmp.Diff(expectedText, text.String()); diff != "" {
+		t.Errorf("Unexpected text delta (-want +got):\n%s", diff)
+	}
+
+	var batch bytes.Buffer
+	if err := writeDelta(&batch, "route53-changebatch", "bar", changes); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Changes []struct {
+			Action            string
+			ResourceRecordSet struct {
+				Name string
+				TTL  uint32
+			}
+		}
+	}
+	if err := json.Unmarshal(batch.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, c := range doc.Changes {
+		actions = append(actions, fmt.Sprintf("%s %s %d", c.Action, c.ResourceRecordSet.Name, c.ResourceRecordSet.TTL))
+	}
+	expectedActions := []string{
+		"DELETE www.bar. 3600",
+		"DELETE old.bar. 3600",
+		"CREATE www.bar. 300",
+		"CREATE new.bar. 3600",
+	}
+	if diff := cmp.Diff(expectedActions, actions); diff != "" {
+		t.Errorf("Unexpected change batch actions (-want +got):\n%s", diff)
+	}
+}
+
+func TestChunkChanges(t *testing.T) {
+	large := dnsRecord{Name: "c.bar.", Type: "A", TTL: 300}
+	for i := 0; i < route53MaxRecordsPerChange-1; i++ {
+		large.Data = append(large.Data, fmt.Sprintf("10.0.%d.%d", i/256, i%256))
+	}
+	cname := dnsRecord{Name: "b.bar.", Type: "CNAME", TTL: 300, Data: []string{"c.bar."}}
+	address := dnsRecord{Name: "b.bar.", Type: "A", TTL: 300, Data: []string{"192.0.2.1"}}
+	changes := zoneDelta(
+		map[recordKey]dnsRecord{{"b.bar.", "CNAME"}: cname},
+		map[recordKey]dnsRecord{{"c.bar.", "A"}: large, {"b.bar.", "A"}: address},
+	)
+
+	// The CNAME replaced by an A record at b.bar. is not split over batches,
+	// although its deletion would fit in the first
+	if diff := cmp.Diff([]int{1, 2}, chunkChanges(changes)); diff != "" {
+		t.Errorf("Unexpected chunks (-want +got):\n%s", diff)
+	}
+}
+
+func TestApply(t *testing.T) {
+	var submitted []string
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+			t.Errorf("Unsigned request %s %s", r.Method, r.URL)
+		}
+		switch {
+		case r.URL.Path == "/2013-04-01/hostedzonesbyname":
+			fmt.Fprint(w, `<ListHostedZonesByNameResponse><HostedZones>
+<HostedZone><Id>/hostedzone/ZTEST</Id><Name>bar.</Name></HostedZone>
+<HostedZone><Id>/hostedzone/ZOTHER</Id><Name>baz.</Name></HostedZone>
+</HostedZones></ListHostedZonesByNameResponse>`)
+		case r.URL.Path == "/2013-04-01/hostedzone/ZTEST/rrset" && r.Method == "GET" && r.URL.Query().Get("name") == "":
+			fmt.Fprint(w, `<ListResourceRecordSetsResponse><ResourceRecordSets>
+<ResourceRecordSet><Name>bar.</Name><Type>NS</Type><TTL>172800</TTL><ResourceRecords><ResourceRecord><Value>ns-1.example.</Value></ResourceRecord></ResourceRecords></ResourceRecordSet>
+<ResourceRecordSet><Name>bar.</Name><Type>SOA</Type><TTL>900</TTL><ResourceRecords><ResourceRecord><Value>ns-1.example. admin.example. 1 7200 900 1209600 86400</Value></ResourceRecord></ResourceRecords></ResourceRecordSet>
+<ResourceRecordSet><Name>old.bar.</Name><Type>A</Type><TTL>300</TTL><ResourceRecords><ResourceRecord><Value>192.0.2.9</Value></ResourceRecord></ResourceRecords></ResourceRecordSet>
+</ResourceRecordSets><IsTruncated>true</IsTruncated><NextRecordName>\052.bar.</NextRecordName><NextRecordType>A</NextRecordType></ListResourceRecordSetsResponse>`)
+		case r.URL.Path == "/2013-04-01/hostedzone/ZTEST/rrset" && r.Method == "GET":
+			fmt.Fprint(w, `<ListResourceRecordSetsResponse><ResourceRecordSets>
+<ResourceRecordSet><Name>\052.bar.</Name><Type>A</Type><TTL>300</TTL><ResourceRecords><ResourceRecord><Value>192.0.2.1</Value></ResourceRecord></ResourceRecords></ResourceRecordSet>
+<ResourceRecordSet><Name>www.bar.</Name><Type>A</Type><SetIdentifier>blue</SetIdentifier><TTL>60</TTL><ResourceRecords><ResourceRecord><Value>192.0.2.7</Value></ResourceRecord></ResourceRecords></ResourceRecordSet>
+<ResourceRecordSet><Name>txt.bar.</Name><Type>TXT</Type><TTL>300</TTL><ResourceRecords><ResourceRecord><Value>"first" "second"</Value></ResourceRecord></ResourceRecords></ResourceRecordSet>
+<ResourceRecordSet><Name>cafe.bar.</Name><Type>TXT</Type><TTL>300</TTL><ResourceRecords><ResourceRecord><Value>"say \"hi\" caf\303\251"</Value></ResourceRecord></ResourceRecords></ResourceRecordSet>
+<ResourceRecordSet><Name>bar.</Name><Type>MX</Type><TTL>300</TTL><ResourceRecords><ResourceRecord><Value>10  Mail.Bar</Value></ResourceRecord></ResourceRecords></ResourceRecordSet>
+<ResourceRecordSet><Name>www.bar.</Name><Type>CNAME</Type><TTL>300</TTL><ResourceRecords><ResourceRecord><Value>Target.Example.</Value></ResourceRecord></ResourceRecords></ResourceRecordSet>
+</ResourceRecordSets><IsTruncated>false</IsTruncated></ListResourceRecordSetsResponse>`)
+		case r.URL.Path == "/2013-04-01/hostedzone/ZTEST/rrset" && r.Method == "POST":
+			var req struct {
+				Changes []struct {
+					Action            string
+					ResourceRecordSet struct {
+						Name            string
+						Type            string
+						TTL             int
+						ResourceRecords []struct {
+							Value string
+						} `xml:"ResourceRecords>ResourceRecord"`
+					}
+				} `xml:"ChangeBatch>Changes>Change"`
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			if err := xml.Unmarshal(body, &req); err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range req.Changes {
+				change := fmt.Sprintf("%s %s %s %d", c.Action, c.ResourceRecordSet.Name, c.ResourceRecordSet.Type, c.ResourceRecordSet.TTL)
+				for _, value := range c.ResourceRecordSet.ResourceRecords {
+					change += " " + value.Value
+				}
+				submitted = append(submitted, change)
+			}
+			fmt.Fprint(w, `<ChangeResourceRecordSetsResponse><ChangeInfo><Id>/change/C1</Id><Status>PENDING</Status></ChangeInfo></ChangeResourceRecordSetsResponse>`)
+		case r.URL.Path == "/2013-04-01/change/C1":
+			polls++
+			status := "PENDING"
+			if polls > 1 {
+				status = "INSYNC"
+			}
+			fmt.Fprintf(w, `<GetChangeResponse><ChangeInfo><Id>/change/C1</Id><Status>%s</Status></ChangeInfo></GetChangeResponse>`, status)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<ErrorResponse><Error><Code>NoSuchHostedZone</Code><Message>not found</Message></Error></ErrorResponse>`)
+		}
+	}))
+	defer server.Close()
+
+	zone := `$ORIGIN bar.
+*     300 IN A   192.0.2.1
+txt   300 IN TXT "first" "second"
+cafe  300 IN TXT "say \"hi\" caf\195\169"
+new   300 IN A   192.0.2.3
+new   300 IN TXT "\195\169t\195\169"
+@     300 IN MX  10 mail.bar.
+www   300 IN CNAME target.example.
+`
+	records := readZoneRecords(strings.NewReader(zone), "", "bar", excludedTypesFromString("SOA,NS"), ttlFirst, &diagnostics{})
+
+	var out bytes.Buffer
+	client, err := newRoute53Client(server.URL, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("id", "secret", "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newApplier(client, strings.NewReader("yes\n"), &out, false, &diagnostics{})
+	a.pollInterval = time.Millisecond
+	if err := a.apply("bar", "", records, excludedTypesFromString("SOA,NS")); err != nil {
+		t.Fatalf("Apply failed: %v\n%s", err, out.String())
+	}
+
+	// Values Route 53 returns in another case, spacing or without the
+	// trailing dot are unchanged
+	expected := []string{
+		"DELETE old.bar. A 300 192.0.2.9",
+		`CREATE new.bar. TXT 300 "\303\251t\303\251"`,
+		"CREATE new.bar. A 300 192.0.2.3",
+	}
+	if diff := cmp.Diff(expected, submitted); diff != "" {
+		t.Errorf("Unexpected changes submitted (-want +got):\n%s", diff)
+	}
+	// The plan shows values as they are submitted, encoded once
+	if !strings.Contains(out.String(), `+ new.bar. 300 IN TXT "\303\251t\303\251"`) {
+		t.Errorf("Expected the plan to show the created TXT value as submitted, got:\n%s", out.String())
+	}
+	if polls != 2 {
+		t.Errorf("Expected the change to be polled until in sync, got %d polls", polls)
+	}
+
+	submitted = nil
+	a = newApplier(a.client, strings.NewReader("no\n"), &out, false, &diagnostics{})
+	if err := a.apply("bar", "ZTEST", records, excludedTypesFromString("SOA,NS")); err == nil {
+		t.Error("Expected apply to be cancelled without confirmation")
+	}
+	if len(submitted) > 0 {
+		t.Errorf("Expected no changes to be submitted without confirmation, got %v", submitted)
+	}
+}
+
+func TestApplyRefusesErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	testCases := map[string]string{
+		// The records after a parse error would be deleted
+		"parse error": "$ORIGIN bar.\nwww 300 IN A 192.0.2.1\nbad 300 IN A not-an-address\nmail 300 IN A 192.0.2.2\n",
+		// As would the record sets Route 53 rejects
+		"invalid record set": "$ORIGIN bar.\nwww 300 IN A 192.0.2.1\nloc 300 IN LOC 52 22 23.000 N 4 53 32.000 E -2.00m 0.00m 10000m 10m\n",
+	}
+	for name, zone := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c, err := New(Options{Domain: "bar", FileName: "bar.zone", Exclude: []string{"SOA", "NS"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			res, err := c.Apply(strings.NewReader(zone), ApplyOptions{
+				HostedZoneID: "ZTEST",
+				Endpoint:     server.URL,
+				Out:          &out,
+				AutoApprove:  true,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Count(Error) < 2 {
+				t.Errorf("Expected the errors and a refusal to apply, got %v", res.Diagnostics)
+			}
+			if out.Len() > 0 {
+				t.Errorf("Expected no plan, got:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	state, err := os.Open("testdata/example.com.tfstate")
+	if err != nil {
+		panic(err)
+	}
+	expected, err := ioutil.ReadFile("testdata/example.com.expected-export")
+	if err != nil {
+		panic(err)
+	}
+
+	var buf bytes.Buffer
+	if err := exportZone(state, "example.com", "", &buf, &diagnostics{}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(expected), buf.String()); diff != "" {
+		t.Errorf("Unexpected zone file exported from state (-want +got):\n%s", diff)
+	}
+
+	// Plans creating the zone do not know its ID yet
+	plan := `{
+  "format_version": "0.1",
+  "planned_values": {
+    "root_module": {
+      "resources": [
+        {"address": "aws_route53_zone.bar", "type": "aws_route53_zone", "values": {"name": "bar"}}
+      ],
+      "child_modules": [
+        {
+          "resources": [
+            {"address": "module.dns.aws_route53_record.www", "type": "aws_route53_record", "values": {"name": "www.bar.", "type": "CNAME", "ttl": 300, "records": ["bar."]}},
+            {"address": "module.dns.aws_route53_record.other", "type": "aws_route53_record", "values": {"name": "www.baz.", "type": "CNAME", "ttl": 300, "records": ["baz."]}}
+          ]
+        }
+      ]
+    }
+  }
+}`
+	buf.Reset()
+	if err := exportZone(strings.NewReader(plan), "bar", "", &buf, &diagnostics{}); err != nil {
+		t.Fatal(err)
+	}
+	expectedPlan := "; bar exported by tfz53 from Terraform\n$ORIGIN bar.\n\n; module.dns.aws_route53_record.www\nwww.bar.\t300\tIN\tCNAME\tbar.\n"
+	if diff := cmp.Diff(expectedPlan, buf.String()); diff != "" {
+		t.Errorf("Unexpected zone file exported from plan (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseHCL(t *testing.T) {
+	src := `# comment
+resource "aws_route53_record" "foo-bar-TXT" {
+  zone_id = "${aws_route53_zone.bar.zone_id}" // legacy
+  name    = "foo.bar."
+  ttl     = 300
+  records = [
+    "first\"\"second", /* inline */
+    "tab\there",
+  ]
+  tags = { a = "b" }
+  lifecycle {
+    ignore_changes = [ttl]
+  }
+}
+
+locals {
+  text = <<-EOT
+    indented
+    text
+  EOT
+  expr = var.a == "b" ? 1 : 2
+}
+`
+	blocks, err := parseHCL(src, "test.tf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 2 {
+		t.Fatalf("Expected 2 blocks, got %d", len(blocks))
+	}
+
+	resource := blocks[0]
+	if diff := cmp.Diff([]string{"aws_route53_record", "foo-bar-TXT"}, resource.Labels); diff != "" {
+		t.Errorf("Unexpected labels (-want +got):\n%s", diff)
+	}
+	if resource.Line != 2 {
+		t.Errorf("Expected resource on line 2, got %d", resource.Line)
+	}
+	for attr, expected := range map[string]string{
+		"name": "foo.bar.",
+		"ttl":  "300",
+	} {
+		if got, ok := resource.Attributes[attr].literal(); !ok || got != expected {
+			t.Errorf("Expected %s to be %q, got %q", attr, expected, got)
+		}
+	}
+	records, ok := resource.Attributes["records"].literalList()
+	if diff := cmp.Diff([]string{`first""second`, "tab\there"}, records); !ok || diff != "" {
+		t.Errorf("Unexpected records (-want +got):\n%s", diff)
+	}
+	for _, attr := range []string{"zone_id", "tags"} {
+		if got, ok := resource.Attributes[attr].literal(); ok {
+			t.Errorf("Expected %s not to be a literal, got %q", attr, got)
+		}
+	}
+	if len(resource.Blocks) != 1 || resource.Blocks[0].Type != "lifecycle" {
+		t.Errorf("Expected a nested lifecycle block, got %v", resource.Blocks)
+	}
+
+	locals := blocks[1].Attributes
+	if text, _ := locals["text"].literal(); text != "indented\ntext\n" {
+		t.Errorf("Unexpected heredoc %q", text)
+	}
+	if got, ok := locals["expr"].literal(); ok {
+		t.Errorf("Expected expression not to be a literal, got %q", got)
+	}
+}
+
+func TestParseHCLMalformed(t *testing.T) {
+	testCases := map[string]string{
+		"list closed by brace": "resource \"x\" \"y\" {\n  records = [\"a\" }\n}\n",
+		"list closed by paren": "resource \"x\" \"y\" {\n  records = [\"a\" )\n}\n",
+		"empty item":           "resource \"x\" \"y\" {\n  records = [,]\n}\n",
+		"unterminated list":    "resource \"x\" \"y\" {\n  records = [\"a\",\n",
+		"unterminated string":  "resource \"x\" \"y\" {\n  records = [\"a]\n}\n",
+		"nested list unclosed": "resource \"x\" \"y\" {\n  records = [[\"a\" }]\n}\n",
+	}
+	for name, src := range testCases {
+		t.Run(name, func(t *testing.T) {
+			done := make(chan error, 1)
+			go func() {
+				_, err := parseHCL(src, "test.tf")
+				done <- err
+			}()
+			select {
+			case err := <-done:
+				if err == nil {
+					t.Errorf("Expected an error parsing %q", src)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Parsing %q did not finish", src)
+			}
+		})
+	}
+}
+
+func TestTerraformDiff(t *testing.T) {
+	excluded := excludedTypesFromString("SOA,NS")
+	zone, err := ioutil.ReadFile("testdata/example.com.zone")
+	if err != nil {
+		panic(err)
+	}
+	records := readZoneRecords(bytes.NewReader(zone), "", "example.com", excluded, ttlFirst, &diagnostics{})
+	namer, err := newResourceNamer(defaultNamingStrategy, "example.com")
+	if err != nil {
+		panic(err)
+	}
+
+	// The generated configuration does not differ from its zone file
+	for _, syntax := range []string{"modern", "legacy"} {
+		dir, err := ioutil.TempDir("", "tfz53")
+		if err != nil {
+			panic(err)
+		}
+		defer os.RemoveAll(dir)
+		generated, err := ioutil.ReadFile("testdata/example.com.expected-route53-" + syntax)
+		if err != nil {
+			panic(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "dns.tf"), generated, 0644); err != nil {
+			panic(err)
+		}
+
+		sets, err := readTerraformRecordSets(dir, excluded, namer, &diagnostics{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changes := terraformDiff(sets, records); len(changes) > 0 {
+			var buf bytes.Buffer
+			writeTerraformDiff(&buf, changes, sets)
+			t.Errorf("Unexpected differences with the %s configuration:\n%s", syntax, buf.String())
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "tfz53")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	config := `resource "aws_route53_record" "mail-example-com-A" {
+  name    = "mail.example.com."
+  type    = "A"
+  ttl     = 300
+  records = ["192.0.2.3"]
+}
+
+resource "aws_route53_record" "extra-example-com-A" {
+  name    = "extra.example.com."
+  type    = "A"
+  ttl     = 300
+  records = ["192.0.2.9"]
+}
+
+# Not generated by tfz53
+resource "aws_route53_record" "custom" {
+  name    = "custom.example.com."
+  type    = "A"
+  ttl     = 300
+  records = [var.address]
+}
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "dns.tf"), []byte(config), 0644); err != nil {
+		panic(err)
+	}
+	onlyMail := map[recordKey]dnsRecord{
+		{"mail.example.com.", "A"}:    records[recordKey{"mail.example.com.", "A"}],
+		{"www.example.com.", "CNAME"}: records[recordKey{"www.example.com.", "CNAME"}],
+	}
+	sets, err := readTerraformRecordSets(dir, excluded, namer, &diagnostics{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := writeTerraformDiff(&buf, terraformDiff(sets, onlyMail), sets); err != nil {
+		t.Fatal(err)
+	}
+	location := filepath.Join(dir, "dns.tf")
+	expected := `added www.example.com. CNAME, missing from Terraform
+  + www.example.com. 3600 IN CNAME example.com.
+changed mail.example.com. A, aws_route53_record.mail-example-com-A at ` + location + `:1 differs from the zone file
+  - mail.example.com. 300 IN A 192.0.2.3
+  + mail.example.com. 3600 IN A 192.0.2.3
+removed extra.example.com. A, aws_route53_record.extra-example-com-A at ` + location + `:8 is not in the zone file
+  - extra.example.com. 300 IN A 192.0.2.9
+`
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Errorf("Unexpected differences (-want +got):\n%s", diff)
+	}
+
+	// Hash names do not change with the TTL and values of the record set
+	hashNamer, err := newResourceNamer("hash", "example.com")
+	if err != nil {
+		panic(err)
+	}
+	config = `resource "aws_route53_record" "A-e9ca0f7a" {
+  name    = "mail.example.com."
+  type    = "A"
+  ttl     = 300
+  records = ["192.0.2.3"]
+}
+`
+	if err := ioutil.WriteFile(location, []byte(config), 0644); err != nil {
+		panic(err)
+	}
+	onlyMail = map[recordKey]dnsRecord{
+		{"mail.example.com.", "A"}: records[recordKey{"mail.example.com.", "A"}],
+	}
+	sets, err = readTerraformRecordSets(dir, excluded, hashNamer, &diagnostics{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := writeTerraformDiff(&buf, terraformDiff(sets, onlyMail), sets); err != nil {
+		t.Fatal(err)
+	}
+	expected = `changed mail.example.com. A, aws_route53_record.A-e9ca0f7a at ` + location + `:1 differs from the zone file
+  - mail.example.com. 300 IN A 192.0.2.3
+  + mail.example.com. 3600 IN A 192.0.2.3
+`
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Errorf("Unexpected differences with hash names (-want +got):\n%s", diff)
+	}
+
+	// Values are compared in canonical form, however they are written
+	config = `resource "aws_route53_record" "example-com-MX" {
+  name    = "example.com."
+  type    = "MX"
+  ttl     = 3600
+  records = ["10  MAIL.example.com", "20 mail2.example.com.", "50 Mail3.Example.Com."]
+}
+
+resource "aws_route53_record" "example-com-AAAA" {
+  name    = "example.com."
+  type    = "AAAA"
+  ttl     = 3600
+  records = ["2001:0db8:0010:0000:0000:0000:0000:0001"]
+}
+`
+	if err := ioutil.WriteFile(location, []byte(config), 0644); err != nil {
+		panic(err)
+	}
+	apex := map[recordKey]dnsRecord{
+		{"example.com.", "MX"}:   records[recordKey{"example.com.", "MX"}],
+		{"example.com.", "AAAA"}: records[recordKey{"example.com.", "AAAA"}],
+	}
+	sets, err = readTerraformRecordSets(dir, excluded, namer, &diagnostics{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := terraformDiff(sets, apex); len(changes) > 0 {
+		buf.Reset()
+		writeTerraformDiff(&buf, changes, sets)
+		t.Errorf("Unexpected differences with values written differently:\n%s", buf.String())
+	}
+
+	// Blocks that cannot be evaluated are reported instead of compared
+	config = `resource "aws_route53_record" "mail-example-com-A" {
+  name    = "mail.example.com."
+  type    = "A"
+  ttl     = 3600
+  records = [local.mail_address]
+}
+
+resource "aws_route53_record" "example-com-A" {
+  for_each = toset(["example.com."])
+  name     = each.key
+  type     = "A"
+  ttl      = 3600
+  records  = ["192.0.2.1"]
+}
+`
+	if err := ioutil.WriteFile(location, []byte(config), 0644); err != nil {
+		panic(err)
+	}
+	diag := &diagnostics{}
+	sets, err = readTerraformRecordSets(dir, excluded, namer, diag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sets) > 0 {
+		t.Errorf("Expected no record sets to be compared, got %v", sets)
+	}
+	var warnings []string
+	for _, d := range diag.list {
+		warnings = append(warnings, d.String())
+	}
+	expectedWarnings := []string{
+		location + ":1: warning: The records of aws_route53_record.mail-example-com-A are not a list of literals, and it is not compared",
+		location + ":8: warning: aws_route53_record.example-com-A is repeated with for_each, which cannot be evaluated, and is not compared",
+	}
+	if diff := cmp.Diff(expectedWarnings, warnings); diff != "" {
+		t.Errorf("Unexpected diagnostics (-want +got):\n%s", diff)
+	}
+}
+
+func TestPlanMoves(t *testing.T) {
+	previousConfig := `resource "aws_route53_record" "www_bar_A" {
+  name    = "www.bar."
+  type    = "A"
+  ttl     = "300"
+  records = ["192.0.2.1"]
+}
+
+resource "aws_route53_record" "mail-bar-A" {
+  name    = "mail.bar."
+  type    = "A"
+  ttl     = "300"
+  records = ["192.0.2.2"]
+}
+
+resource "aws_route53_record" "a-bar-TXT" {
+  name    = "a.bar."
+  type    = "TXT"
+  ttl     = "300"
+  records = ["same"]
+}
+
+resource "aws_route53_record" "b-bar-TXT" {
+  name    = "b.bar."
+  type    = "TXT"
+  ttl     = "300"
+  records = ["same"]
+}
+
+resource "aws_route53_record" "kept-bar-A" {
+  name    = "kept.bar."
+  type    = "A"
+  ttl     = "300"
+  records = ["192.0.2.3"]
+}
+`
+	previous, err := readPreviousResources([]byte(previousConfig), "previous.tf", "bar", &diagnostics{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current := map[string]dnsRecord{
+		// Renamed resource of the same record set
+		"www-bar-A": {Name: "www.bar.", Type: "A", TTL: 60, Data: []string{"192.0.2.1"}},
+		// Renamed record with the same values
+		"post-bar-A": {Name: "post.bar.", Type: "A", TTL: 300, Data: []string{"192.0.2.2"}},
+		// Both TXT records renamed, with the same values
+		"c-bar-TXT":  {Name: "c.bar.", Type: "TXT", TTL: 300, Data: []string{`"same"`}},
+		"d-bar-TXT":  {Name: "d.bar.", Type: "TXT", TTL: 300, Data: []string{`"same"`}},
+		"kept-bar-A": {Name: "kept.bar.", Type: "A", TTL: 300, Data: []string{"192.0.2.3"}},
+	}
+
+	expected := []resourceMove{
+		{"aws_route53_record.mail-bar-A", "aws_route53_record.post-bar-A"},
+		{"aws_route53_record.www_bar_A", "aws_route53_record.www-bar-A"},
+	}
+	if diff := cmp.Diff(expected, planMoves(previous, current, &diagnostics{}), cmp.AllowUnexported(resourceMove{})); diff != "" {
+		t.Errorf("Unexpected moves (-want +got):\n%s", diff)
+	}
+}
+
+func TestLogicalIDs(t *testing.T) {
+	testCases := map[string]struct {
+		naming   string
+		names    []string
+		expected []string
+	}{
+		"fqdn": {
+			naming:   "fqdn",
+			names:    []string{"*.example.com.", "www.example.com."},
+			expected: []string{"RecordWildcardExampleComA", "RecordWwwExampleComA"},
+		},
+		"relative": {
+			naming:   "relative",
+			names:    []string{"example.com.", "www.example.com."},
+			expected: []string{"RecordApexA", "RecordWwwA"},
+		},
+		// a-b and a_b both become RecordAB, and the suffix for the second
+		// skips RecordAB2, which a-b2 has by itself
+		"collisions": {
+			naming:   "{{.Relative}}",
+			names:    []string{"a-b.example.com.", "a-b2.example.com.", "a_b.example.com."},
+			expected: []string{"RecordAB", "RecordAB2", "RecordAB3"},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			namer, err := newResourceNamer(tc.naming, "example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys := make([]recordKey, len(tc.names))
+			for i, name := range tc.names {
+				keys[i] = recordKey{name, "A"}
+			}
+			if err := namer.assign(keys, &diagnostics{}); err != nil {
+				t.Fatal(err)
+			}
+			ids := logicalIDs(keys, namer)
+			got := make([]string, len(keys))
+			for i, key := range keys {
+				got[i] = ids[key]
+			}
+			if diff := cmp.Diff(tc.expected, got); diff != "" {
+				t.Errorf("Unexpected logical IDs (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCanonicalData(t *testing.T) {
+	testCases := map[string]string{
+		"www IN CNAME Target.Example.COM.":     "target.example.com.",
+		"@ IN MX 10 MAIL.example.com.":         "10 mail.example.com.",
+		"@ IN NS NS1.Example.com.":             "ns1.example.com.",
+		"_sip._tcp IN SRV 10 60 5060 SIP.bar.": "10 60 5060 sip.bar.",
+		"1 IN PTR Host.Bar.":                   "host.bar.",
+		"sub IN DNAME Other.Bar.":              "other.bar.",
+		"@ IN CAA 0 ISSUE \"ca.example.net\"":  `0 issue "ca.example.net"`,
+		"v6 IN AAAA 2001:DB8:0:0:0:0:0:1":      "2001:db8::1",
+		"v6 IN AAAA 2001:db8:0:0:1:0:0:1":      "2001:db8::1:0:0:1",
+		"v6 IN AAAA ::FFFF:192.0.2.1":          "::ffff:192.0.2.1",
+		"v4 IN A 192.0.2.1":                    "192.0.2.1",
+	}
+	for line, expected := range testCases {
+		rr, err := dns.NewRR("$ORIGIN bar.\n" + line)
+		if err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+		if got := canonicalData(rr); got != expected {
+			t.Errorf("%s: expected %q, got %q", line, expected, got)
+		}
+	}
+}
+
+func TestSortValues(t *testing.T) {
+	values := []string{"20 mx2.bar.", "5 mx3.bar.", "10 mx1.bar.", "10 mx0.bar."}
+	sortValues(values)
+	if diff := cmp.Diff([]string{"5 mx3.bar.", "10 mx0.bar.", "10 mx1.bar.", "20 mx2.bar."}, values); diff != "" {
+		t.Errorf("Unexpected MX order (-want +got):\n%s", diff)
+	}
+
+	values = []string{"192.0.2.10", "192.0.2.9", "10.0.0.1"}
+	sortValues(values)
+	if diff := cmp.Diff([]string{"10.0.0.1", "192.0.2.9", "192.0.2.10"}, values); diff != "" {
+		t.Errorf("Unexpected A order (-want +got):\n%s", diff)
+	}
+}
+
+func TestTXTRoundTrip(t *testing.T) {
+	testCases := map[string]struct {
+		data     string
+		expected []string
+	}{
+		"escaped quotes":   {`"say \"hi\""`, []string{`say "hi"`}},
+		"semicolons":       {`"v=DKIM1; k=rsa; p=MIGf"`, []string{"v=DKIM1; k=rsa; p=MIGf"}},
+		"backslash":        {`"a\\b"`, []string{`a\b`}},
+		"decimal escape":   {`"caf\195\169"`, []string{"café"}},
+		"dkim key":         {`"v=DKIM1; p=MIIBIj" "ANBgkqhkiG9w0B" "AQEFAAOCAQ8A"`, []string{"v=DKIM1; p=MIIBIj", "ANBgkqhkiG9w0B", "AQEFAAOCAQ8A"}},
+		"separator inside": {`"a\"\"b" "c"`, []string{`a""b`, "c"}},
+		"trailing space":   {`"a " "b"`, []string{"a ", "b"}},
+		"empty":            {`""`, []string{""}},
+		"long":             {`"` + strings.Repeat("x", 300) + `"`, []string{strings.Repeat("x", 255), strings.Repeat("x", 45)}},
+		"long escaped":     {`"` + strings.Repeat(`\"`, 200) + `"`, []string{strings.Repeat(`"`, 200)}},
+		"unquoted":         {`v=spf1`, []string{"v=spf1"}},
+		"interpolation":    {`"${var.x}"`, []string{"${var.x}"}},
+		"directive":        {`"%{if x}y%{endif}"`, []string{"%{if x}y%{endif}"}},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			expected := make([]byte, 0)
+			for _, s := range tc.expected {
+				expected = append(expected, byte(len(s)))
+				expected = append(expected, s...)
+			}
+
+			rr, err := dns.NewRR(". 0 IN TXT " + tc.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data := canonicalData(rr)
+			hcl, err := parseHCL(fmt.Sprintf("resource \"a\" \"b\" {\n  x = %s\n  y = [%s]\n}\n", terraformString(terraformTXT(data)), googleRRData("TXT", data)), "test.tf")
+			if err != nil {
+				t.Fatal(err)
+			}
+			terraformValue, _ := hcl[0].Attributes["x"].literal()
+			googleValues, _ := hcl[0].Attributes["y"].literalList()
+
+			for source, d := range map[string]string{
+				"record data":   data,
+				"Route 53 API":  formatTXT(parseTXT(route53Value("TXT", data), true), false),
+				"Terraform":     txtFromTerraform(terraformValue),
+				"Cloud DNS":     googleValues[0],
+				"joined string": formatTXT(splitTXT([]string{joinedTXT(data)}), false),
+			} {
+				if source == "joined string" && len(tc.expected) > 1 && len(tc.expected[0]) < txtMaxStringLength {
+					// Joining keeps only the strings split at 255 bytes
+					continue
+				}
+				if diff := cmp.Diff(expected, txtWireData(t, d)); diff != "" {
+					t.Errorf("Unexpected wire format from %s %s (-want +got):\n%s", source, d, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestTerraformString(t *testing.T) {
+	testCases := map[string]string{
+		`say "hi"`:         `"say \"hi\""`,
+		`a\b`:              `"a\\b"`,
+		"a\tb\n":           `"a\tb\n"`,
+		"${var.x}":         `"$${var.x}"`,
+		"%{if x}y%{endif}": `"%%{if x}y%%{endif}"`,
+		"100% $5":          `"100% $5"`,
+	}
+	for s, expected := range testCases {
+		if got := terraformString(s); got != expected {
+			t.Errorf("Expected %s to be quoted as %s, got %s", s, expected, got)
+		}
+	}
+}
+
+// txtWireData returns the RDATA of TXT record data in wire format, parsed by
+// miekg/dns and with the strings it splits joined again.
+func txtWireData(t *testing.T, data string) []byte {
+	rr, err := dns.NewRR(". 0 IN TXT " + data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txt := rr.(*dns.TXT)
+	strs := txtFromParsed(txt.Txt)
+	txt.Txt = make([]string, len(strs))
+	for i, s := range strs {
+		txt.Txt[i] = escapeTXT(s, false)
+	}
+
+	buf := make([]byte, 4096)
+	off, err := dns.PackRR(rr, buf, 0, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf[off-int(rr.Header().Rdlength) : off]
+}
+
+func TestRoute53Validation(t *testing.T) {
+	var zone strings.Builder
+	zone.WriteString(`$ORIGIN bar.
+old    300 IN DNAME new.bar.
+loc    300 IN LOC   52 22 23.000 N 4 53 32.000 E -2.00m 0.00m 10000m 10m
+both   300 IN TXT   "v=spf1 -all"
+both   300 IN SPF   "v=spf1 -all"
+spf    300 IN SPF   "v=spf1 mx -all"
+other  300 IN TXT   "v=spf1 -all"
+other  300 IN SPF   "v=spf1 mx -all"
+long   300 IN TXT   ` + strings.Repeat(`"`+strings.Repeat("x", 255)+`" `, 16) + `
+`)
+	for i := 0; i < 401; i++ {
+		fmt.Fprintf(&zone, "many 300 IN A 10.0.%d.%d\n", i/256, i%256)
+	}
+
+	c, err := New(Options{Domain: "bar", FileName: "bar.zone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.Convert(strings.NewReader(zone.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"bar.zone:6: warning: Converting SPF spf.bar. to TXT, as the SPF type is deprecated (replace the SPF record with a TXT record)",
+		"bar.zone:8: warning: Keeping SPF other.bar., as its TXT record has other values (merge the SPF values into the TXT record, keeping a single v=spf1 policy, and remove the SPF record)",
+		"bar.zone:5: warning: Skipping SPF both.bar., as its TXT record has the same values (remove the SPF record)",
+		"bar.zone:2: error: Route 53 does not support DNAME records, skipping old.bar. (replace it with CNAME records for the names in use below it)",
+		"bar.zone:10: error: many.bar. A has 401 values, more than the 400 Route 53 allows in a record set, skipping the record set (spread the values over several names)",
+		"bar.zone:9: error: A value of long.bar. TXT has 4127 characters, more than the 4000 Route 53 allows, skipping the record set (shorten the value)",
+		"bar.zone:3: error: Route 53 does not support LOC records, skipping loc.bar. (remove it, or publish the information in a TXT record)",
+	}
+	var got []string
+	for _, d := range res.Diagnostics {
+		got = append(got, d.String())
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("Unexpected diagnostics (-want +got):\n%s", diff)
+	}
+
+	var sets []string
+	for _, rec := range res.Records {
+		sets = append(sets, rec.Name+" "+rec.Type)
+	}
+	if diff := cmp.Diff([]string{"both.bar. TXT", "other.bar. SPF", "other.bar. TXT", "spf.bar. TXT"}, sets); diff != "" {
+		t.Errorf("Unexpected record sets (-want +got):\n%s", diff)
+	}
+
+	label := strings.Repeat("a", 63)
+	for name, valid := range map[string]bool{
+		label + ".bar.":                      true,
+		label + "a.bar.":                     false,
+		strings.Repeat(`\097`, 63) + ".bar.": true,
+		strings.Repeat(label+".", 3) + strings.Repeat("b", 61) + ".": true,
+		strings.Repeat(label+".", 3) + strings.Repeat("b", 62) + ".": false,
+	} {
+		if _, ok := checkNameLength(name); ok != valid {
+			t.Errorf("Expected checkNameLength(%q) to be %v", name, valid)
+		}
+	}
+
+	records := make(map[recordKey]dnsRecord)
+	for i := 0; i <= route53DefaultRecordSetQuota; i++ {
+		key := recordKey{fmt.Sprintf("r%d.bar.", i), "A"}
+		records[key] = dnsRecord{Name: key.Name, Type: key.Type, Data: []string{"192.0.2.1"}}
+	}
+	diag := &diagnostics{}
+	validateRoute53(records, diag)
+	if len(diag.list) != 1 || diag.list[0].Severity != Warning || diag.list[0].Fix == "" {
+		t.Errorf("Expected a warning about the record set quota, got %v", diag.list)
+	}
+}
+
+func TestLint(t *testing.T) {
+	zone := `$ORIGIN bar.
+@          300 IN NS    ns1.bar.
+@          300 IN NS    ns2.bar.
+@          300 IN MX    10 mail.bar.
+ns1        300 IN A     192.0.2.1
+ns2        300 IN CNAME ns1.bar.
+mail       300 IN CNAME mx.example.com.
+www        300 IN CNAME web.bar.
+www        300 IN TXT   "site"
+*          300 IN A     192.0.2.2
+api        30  IN TXT   "v=1"
+a.deep     300 IN A     192.0.2.3
+_sip._tcp  300 IN SRV   10 60 5060 sip.bar.
+foo.com.   300 IN A     192.0.2.4
+`
+	c, err := New(Options{Domain: "bar", FileName: "bar.zone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.Lint(strings.NewReader(zone), LintOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"bar.zone:2: warning: bar. NS points at ns2.bar., which is a CNAME record (point it at the target of the CNAME record) [target-is-cname]",
+		"bar.zone:4: warning: bar. MX points at mail.bar., which is a CNAME record (point it at the target of the CNAME record) [target-is-cname]",
+		"bar.zone:8: error: www.bar. has a CNAME record and TXT records (remove the CNAME record, or the other records) [cname-and-other-data]",
+		"bar.zone:11: warning: api.bar. has no A record, as it shadows the wildcard *.bar. at line 10 (add the A record to api.bar.) [wildcard-shadowed]",
+		"bar.zone:11: warning: TTL 30 of api.bar. TXT is below 60 (raise the TTL to at least 60) [low-ttl]",
+		"bar.zone:12: warning: deep.bar. has no A record, as it shadows the wildcard *.bar. at line 10 (add the A record to deep.bar.) [wildcard-shadowed]",
+		"bar.zone:12: info: deep.bar. has no records, but exists as a parent of a.deep.bar. [empty-non-terminal]",
+		"bar.zone:13: warning: _tcp.bar. has no A record, as it shadows the wildcard *.bar. at line 10 (add the A record to _tcp.bar.) [wildcard-shadowed]",
+		"bar.zone:13: info: _tcp.bar. has no records, but exists as a parent of _sip._tcp.bar. [empty-non-terminal]",
+		"bar.zone:14: error: foo.com. A is outside the zone bar. (remove it, or move it to the zone of its name) [out-of-zone]",
+	}
+	var got []string
+	for _, f := range res.Findings {
+		got = append(got, f.String())
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("Unexpected findings (-want +got):\n%s", diff)
+	}
+
+	res, err = c.Lint(strings.NewReader(zone), LintOptions{
+		Format: "sarif",
+		Rules:  map[string]string{"wildcard-shadowed": "off", "empty-non-terminal": "off", "target-is-cname": "off", "unresolvable-target": "off", "out-of-zone": "off", "cname-loop": "off", "low-ttl": "error"},
+		MinTTL: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedSARIF := `{
+  "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+  "version": "2.1.0",
+  "runs": [
+    {
+      "tool": {
+        "driver": {
+          "name": "tfz53",
+          "informationUri": "https://github.com/carlpett/tfz53",
+          "rules": [
+            {
+              "id": "cname-and-other-data",
+              "shortDescription": {
+                "text": "A name with a CNAME record has no other records, and a single CNAME value"
+              },
+              "defaultConfiguration": {
+                "level": "error"
+              }
+            },
+            {
+              "id": "low-ttl",
+              "shortDescription": {
+                "text": "TTLs are at least the minimum TTL"
+              },
+              "defaultConfiguration": {
+                "level": "error"
+              }
+            },
+            {
+              "id": "subdomain-takeover",
+              "shortDescription": {
+                "text": "CNAME records and aliases do not point at services prone to subdomain takeover"
+              },
+              "defaultConfiguration": {
+                "level": "error"
+              },
+              "properties": {
+                "security-severity": "8.0",
+                "tags": [
+                  "security"
+                ]
+              }
+            }
+          ]
+        }
+      },
+      "results": [
+        {
+          "ruleId": "cname-and-other-data",
+          "level": "error",
+          "message": {
+            "text": "www.bar. has a CNAME record and TXT records. Fix: remove the CNAME record, or the other records"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "bar.zone"
+                },
+                "region": {
+                  "startLine": 8
+                }
+              }
+            }
+          ]
+        }
+      ]
+    }
+  ]
+}
+`
+	if diff := cmp.Diff(expectedSARIF, string(res.Output)); diff != "" {
+		t.Errorf("Unexpected SARIF (-want +got):\n%s", diff)
+	}
+
+	if _, err := c.Lint(strings.NewReader(zone), LintOptions{Rules: map[string]string{"no-such-rule": "off"}}); err == nil {
+		t.Error("Expected an unknown rule to fail")
+	}
+	if _, err := c.Lint(strings.NewReader(zone), LintOptions{Rules: map[string]string{"low-ttl": "fatal"}}); err == nil {
+		t.Error("Expected an unknown severity to fail")
+	}
+}
+
+func TestLintReferences(t *testing.T) {
+	zone := `$ORIGIN bar.
+@      300 IN MX    10 mail.bar.
+mail   300 IN CNAME mx.bar.
+mx     300 IN CNAME gone.bar.
+loop1  300 IN CNAME loop2.bar.
+loop2  300 IN CNAME loop1.bar.
+srv    300 IN SRV   10 60 5060 txt.bar.
+txt    300 IN TXT   "no address"
+web    300 IN CNAME www.baz.
+api    300 IN CNAME api.baz.
+ext    300 IN CNAME example.com.
+`
+	sibling := `$ORIGIN baz.
+www 300 IN A 192.0.2.1
+`
+	c, err := New(Options{Domain: "bar", FileName: "bar.zone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := map[string]string{"target-is-cname": "off"}
+	res, err := c.Lint(strings.NewReader(zone), LintOptions{
+		Rules:    rules,
+		Siblings: []SiblingZone{{Domain: "baz", FileName: "baz.zone", Zone: strings.NewReader(sibling)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"bar.zone:2: warning: bar. MX points at mail.bar. -> mx.bar. -> gone.bar., which does not exist in the zone bar. (remove the record, or add the target) [unresolvable-target]",
+		"bar.zone:3: warning: mail.bar. CNAME points at mx.bar. -> gone.bar., which does not exist in the zone bar. (remove the record, or add the target) [unresolvable-target]",
+		"bar.zone:4: warning: mx.bar. CNAME points at gone.bar., which does not exist in the zone bar. (remove the record, or add the target) [unresolvable-target]",
+		"bar.zone:5: error: loop1.bar. CNAME points at loop2.bar. -> loop1.bar. -> loop2.bar., which is a CNAME loop (point one of the CNAME records at a name with records) [cname-loop]",
+		"bar.zone:6: error: loop2.bar. CNAME points at loop1.bar. -> loop2.bar. -> loop1.bar., which is a CNAME loop (point one of the CNAME records at a name with records) [cname-loop]",
+		"bar.zone:7: warning: srv.bar. SRV points at txt.bar., which has no A or AAAA records (add A or AAAA records to txt.bar.) [unresolvable-target]",
+		"bar.zone:10: warning: api.bar. CNAME points at api.baz., which does not exist in the zone baz. (remove the record, or add the target) [unresolvable-target]",
+	}
+	var got []string
+	for _, f := range res.Findings {
+		got = append(got, f.String())
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("Unexpected findings (-want +got):\n%s", diff)
+	}
+
+	// Without the sibling zone, its names cannot be resolved and are not
+	// reported
+	res, err = c.Lint(strings.NewReader(zone), LintOptions{Rules: rules})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range res.Findings {
+		if strings.Contains(f.Message, "baz.") {
+			t.Errorf("Unexpected finding for a target outside the batch: %s", f)
+		}
+	}
+}
+
+func TestLintSubdomainTakeover(t *testing.T) {
+	zone := `$ORIGIN bar.
+assets  300 IN CNAME assets.bar.s3.amazonaws.com.
+app     300 IN CNAME bar-app.herokuapp.com.
+docs    300 IN CNAME bar.github.io.
+site    300 IN CNAME site.azurewebsites.net.
+shop    300 IN CNAME shop.example.net.
+safe    300 IN CNAME github.io.example.org.
+; alias www.bar. A -> s3-website-eu-west-1.amazonaws.com. (hosted zone Z1BKCTXD74EZPE, evaluate target health false)
+www     300 IN TXT   "alias"
+`
+	c, err := New(Options{Domain: "bar", FileName: "bar.zone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.Lint(strings.NewReader(zone), LintOptions{
+		Rules:            map[string]string{"unresolvable-target": "off", "empty-non-terminal": "off"},
+		TakeoverSuffixes: map[string]string{"example.net": "Example Shops", "herokuapp.com": ""},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"bar.zone:2: error: assets.bar. CNAME points at assets.bar.s3.amazonaws.com., an endpoint of AWS S3 that can be taken over once deprovisioned (confirm the AWS S3 resource is still owned, or remove the record) [subdomain-takeover]",
+		"bar.zone:3: error: app.bar. CNAME points at bar-app.herokuapp.com., an endpoint of Heroku that can be taken over once deprovisioned (confirm the Heroku resource is still owned, or remove the record) [subdomain-takeover]",
+		"bar.zone:4: error: docs.bar. CNAME points at bar.github.io., an endpoint of GitHub Pages that can be taken over once deprovisioned (confirm the GitHub Pages resource is still owned, or remove the record) [subdomain-takeover]",
+		"bar.zone:5: error: site.bar. CNAME points at site.azurewebsites.net., an endpoint of Azure App Service that can be taken over once deprovisioned (confirm the Azure App Service resource is still owned, or remove the record) [subdomain-takeover]",
+		"bar.zone:6: error: shop.bar. CNAME points at shop.example.net., an endpoint of Example Shops that can be taken over once deprovisioned (confirm the Example Shops resource is still owned, or remove the record) [subdomain-takeover]",
+		"bar.zone:8: error: Alias www.bar. A points at s3-website-eu-west-1.amazonaws.com., an endpoint of AWS S3 that can be taken over once deprovisioned (confirm the AWS S3 resource is still owned, or remove the alias) [subdomain-takeover]",
+	}
+	var got []string
+	for _, f := range res.Findings {
+		got = append(got, f.String())
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("Unexpected findings (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,218 @@
+package converter
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type changeKind string
+
+const (
+	changeCreated  changeKind = "created"
+	changeDeleted  changeKind = "deleted"
+	changeModified changeKind = "modified"
+)
+
+// recordSetChange is the difference of a single record set between two
+// versions of a zone. Old is nil for created sets, and New for deleted ones.
+type recordSetChange struct {
+	Key  recordKey
+	Kind changeKind
+	Old  *dnsRecord
+	New  *dnsRecord
+}
+
+// zoneDelta compares the record sets of two versions of a zone. A set is
+// modified when its TTL or values changed, regardless of the order of the
+// values. Comments and annotations are not compared.
+func zoneDelta(oldRecords, newRecords map[recordKey]dnsRecord) []recordSetChange {
+	all := make(map[recordKey]dnsRecord, len(oldRecords))
+	for key, rec := range oldRecords {
+		all[key] = rec
+	}
+	for key, rec := range newRecords {
+		all[key] = rec
+	}
+
+	var changes []recordSetChange
+	for _, key := range sortedRecordKeys(all) {
+		oldRec, inOld := oldRecords[key]
+		newRec, inNew := newRecords[key]
+		switch {
+		case !inOld:
+			changes = append(changes, recordSetChange{key, changeCreated, nil, &newRec})
+		case !inNew:
+			changes = append(changes, recordSetChange{key, changeDeleted, &oldRec, nil})
+		case oldRec.TTL != newRec.TTL || !reflect.DeepEqual(sortedData(oldRec), sortedData(newRec)):
+			changes = append(changes, recordSetChange{key, changeModified, &oldRec, &newRec})
+		}
+	}
+	return changes
+}
+
+func sortedData(rec dnsRecord) []string {
+	data := append([]string(nil), rec.Data...)
+	sort.Strings(data)
+	return data
+}
+
+// writeDelta writes the changes in the given format.
+func writeDelta(w io.Writer, format, domain string, changes []recordSetChange) error {
+	switch format {
+	case "text":
+		return writeDeltaText(w, changes)
+	case "json":
+		return writeDeltaJSON(w, domain, changes)
+	case "route53-changebatch":
+		return writeDeltaChangeBatches(w, domain, changes)
+	default:
+		return fmt.Errorf("Unknown delta format %q, must be one of text, json, route53-changebatch", format)
+	}
+}
+
+// writeDeltaText writes the changes as a readable diff, listing the removed
+// and added records of each set in zone file notation.
+func writeDeltaText(w io.Writer, changes []recordSetChange) error {
+	var b strings.Builder
+	counts := make(map[changeKind]int)
+	for _, c := range changes {
+		counts[c.Kind]++
+		fmt.Fprintf(&b, "%s %s %s\n", c.Kind, c.Key.Name, c.Key.Type)
+		writeChangeRecords(&b, c)
+	}
+	if len(changes) == 0 {
+		b.WriteString("No changes\n")
+	} else {
+		fmt.Fprintf(&b, "%d created, %d deleted, %d modified\n", counts[changeCreated], counts[changeDeleted], counts[changeModified])
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeChangeRecords lists the removed and added records of the change.
+func writeChangeRecords(b *strings.Builder, c recordSetChange) {
+	for _, side := range []struct {
+		sign string
+		rec  *dnsRecord
+	}{{"-", c.Old}, {"+", c.New}} {
+		if side.rec == nil {
+			continue
+		}
+		for _, d := range side.rec.Data {
+			fmt.Fprintf(b, "  %s %s %d IN %s %s\n", side.sign, side.rec.Name, side.rec.TTL, side.rec.Type, route53Value(side.rec.Type, d))
+		}
+	}
+}
+
+// writeDeltaJSON writes the changes as a JSON document, with the TTL and
+// values of each set before and after the change.
+func writeDeltaJSON(w io.Writer, domain string, changes []recordSetChange) error {
+	items := make([]interface{}, len(changes))
+	for i, c := range changes {
+		item := yamlMap{
+			{"name", c.Key.Name},
+			{"type", c.Key.Type},
+			{"change", string(c.Kind)},
+		}
+		if c.Old != nil {
+			item = append(item, yamlField{"old", deltaRecordSet(*c.Old)})
+		}
+		if c.New != nil {
+			item = append(item, yamlField{"new", deltaRecordSet(*c.New)})
+		}
+		items[i] = item
+	}
+	return writeJSON(w, yamlMap{
+		{"zone", strings.TrimRight(domain, ".")},
+		{"changes", items},
+	})
+}
+
+func deltaRecordSet(rec dnsRecord) yamlMap {
+	values := make([]interface{}, len(rec.Data))
+	for i, d := range rec.Data {
+		values[i] = route53Value(rec.Type, d)
+	}
+	return yamlMap{
+		{"ttl", rec.TTL},
+		{"values", values},
+	}
+}
+
+// deltaChangeSizes returns the size of each change in a Route 53 change
+// request, counting both the deletion and creation of modified sets.
+func deltaChangeSizes(changes []recordSetChange) []route53ChangeSize {
+	sizes := make([]route53ChangeSize, len(changes))
+	for i, c := range changes {
+		for _, rec := range []*dnsRecord{c.Old, c.New} {
+			if rec != nil {
+				sizes[i] = sizes[i].add(recordSetChangeSize(*rec, 1))
+			}
+		}
+	}
+	return sizes
+}
+
+// chunkChanges splits the changes, sorted by name, into chunks that fit in a
+// single Route 53 change request. The changes of a name are kept in the same
+// chunk, so that replacing a record set by one of another type, such as a
+// CNAME by A records, is applied atomically.
+func chunkChanges(changes []recordSetChange) []int {
+	sizes := deltaChangeSizes(changes)
+	var names []int
+	var nameSizes []route53ChangeSize
+	for i, c := range changes {
+		if i == 0 || c.Key.Name != changes[i-1].Key.Name {
+			names = append(names, 0)
+			nameSizes = append(nameSizes, route53ChangeSize{})
+		}
+		names[len(names)-1]++
+		nameSizes[len(nameSizes)-1] = nameSizes[len(nameSizes)-1].add(sizes[i])
+	}
+
+	var chunks []int
+	for _, n := range chunkBySize(nameSizes) {
+		chunk := 0
+		for _, count := range names[:n] {
+			chunk += count
+		}
+		names = names[n:]
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// writeDeltaChangeBatches writes the changes as Route 53 ChangeBatch
+// documents. Modified sets are deleted with their old values and created
+// again in the same batch, which Route 53 applies atomically, as a DELETE
+// must match the current record set exactly. Each batch deletes before it
+// creates, so that a record set may replace one of another type.
+func writeDeltaChangeBatches(w io.Writer, domain string, changes []recordSetChange) error {
+	chunks := chunkChanges(changes)
+	for idx, n := range chunks {
+		var items []interface{}
+		for _, c := range changes[:n] {
+			if c.Old != nil {
+				items = append(items, changeBatchItem("DELETE", *c.Old))
+			}
+		}
+		for _, c := range changes[:n] {
+			if c.New != nil {
+				items = append(items, changeBatchItem("CREATE", *c.New))
+			}
+		}
+		changes = changes[n:]
+
+		batch := yamlMap{
+			{"Comment", fmt.Sprintf("Update of %s by tfz53, batch %d of %d", strings.TrimRight(domain, "."), idx+1, len(chunks))},
+			{"Changes", items},
+		}
+		if err := writeJSON(w, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Severity grades a diagnostic.
+type Severity uint8
+
+const (
+	// Info notes something that was done as asked, such as excluding a
+	// record.
+	Info Severity = iota
+	// Warning reports a record that was changed or left out, where the
+	// output may not be what was intended.
+	Warning
+	// Error reports a record that could not be converted and was left out.
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	default:
+		panic("Unknown severity")
+	}
+}
+
+func severityFromString(s string) (Severity, error) {
+	for _, severity := range []Severity{Info, Warning, Error} {
+		if s == severity.String() {
+			return severity, nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown severity %q, must be one of info, warning, error", s)
+}
+
+// MarshalText encodes the severity by its name, such as in JSON.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Diagnostic is a problem found during a call, located in the zone file when
+// it concerns a record. Line is 0 when the location is not known. Fix
+// suggests how to resolve the problem in the zone file, if known. Rule is the
+// ID of the lint rule that reported the finding.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	File     string   `json:"file,omitempty"`
+	Line     int      `json:"line,omitempty"`
+	Message  string   `json:"message"`
+	Fix      string   `json:"fix,omitempty"`
+	Rule     string   `json:"rule,omitempty"`
+}
+
+// String formats the diagnostic like file:line: severity: message (fix)
+// [rule].
+func (d Diagnostic) String() string {
+	message := d.Message
+	if d.Fix != "" {
+		message = fmt.Sprintf("%s (%s)", message, d.Fix)
+	}
+	if d.Rule != "" {
+		message = fmt.Sprintf("%s [%s]", message, d.Rule)
+	}
+	switch {
+	case d.File != "" && d.Line > 0:
+		return fmt.Sprintf("%s:%d: %s: %s", d.File, d.Line, d.Severity, message)
+	case d.Line > 0:
+		return fmt.Sprintf("line %d: %s: %s", d.Line, d.Severity, message)
+	case d.File != "":
+		return fmt.Sprintf("%s: %s: %s", d.File, d.Severity, message)
+	default:
+		return fmt.Sprintf("%s: %s", d.Severity, message)
+	}
+}
+
+// diagnostics collects the diagnostics of a single call, instead of logging
+// them, so that concurrent calls do not share any state. In strict mode,
+// warnings are collected as errors.
+type diagnostics struct {
+	list   []Diagnostic
+	strict bool
+}
+
+func (d *diagnostics) add(severity Severity, file string, line int, format string, args ...interface{}) {
+	if d.strict && severity == Warning {
+		severity = Error
+	}
+	d.list = append(d.list, Diagnostic{
+		Severity: severity,
+		File:     file,
+		Line:     line,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (d *diagnostics) warnf(format string, args ...interface{}) {
+	d.add(Warning, "", 0, format, args...)
+}
+
+func (d *diagnostics) errorf(format string, args ...interface{}) {
+	d.add(Error, "", 0, format, args...)
+}
+
+// hasErrors reports whether any error has been collected.
+func (d *diagnostics) hasErrors() bool {
+	for _, diagnostic := range d.list {
+		if diagnostic.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// recordInfof, recordWarnf and recordErrorf report a diagnostic at the first
+// line of the record set.
+func (d *diagnostics) recordInfof(rec dnsRecord, format string, args ...interface{}) {
+	d.add(Info, rec.File, rec.Line, format, args...)
+}
+
+func (d *diagnostics) recordWarnf(rec dnsRecord, format string, args ...interface{}) {
+	d.add(Warning, rec.File, rec.Line, format, args...)
+}
+
+func (d *diagnostics) recordErrorf(rec dnsRecord, format string, args ...interface{}) {
+	d.add(Error, rec.File, rec.Line, format, args...)
+}
+
+// fixf reports a diagnostic with a suggested fix.
+func (d *diagnostics) fixf(severity Severity, file string, line int, fix, format string, args ...interface{}) {
+	d.add(severity, file, line, format, args...)
+	d.list[len(d.list)-1].Fix = fix
+}
+
+// recordFixf reports a diagnostic at the first line of the record set, with a
+// suggested fix.
+func (d *diagnostics) recordFixf(severity Severity, rec dnsRecord, fix, format string, args ...interface{}) {
+	d.fixf(severity, rec.File, rec.Line, fix, format, args...)
+}
+
+var parseErrorPattern = regexp.MustCompile(`^(?:(.*): )?dns: (.*) at line: (\d+):(\d+)$`)
+
+// parseError reports an error of the zone file parser at its location.
+func (d *diagnostics) parseError(err error, file string) {
+	m := parseErrorPattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		d.add(Error, file, 0, "%v", err)
+		return
+	}
+	if m[1] != "" {
+		file = m[1]
+	}
+	line, _ := strconv.Atoi(m[3])
+	d.add(Error, file, line, "%s at column %s, the rest of the file is skipped", m[2], m[4])
+}
@@ -0,0 +1,159 @@
+package converter
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// terraformRecordSet is an aws_route53_record block generated by tfz53, found
+// in a configuration file.
+type terraformRecordSet struct {
+	record   dnsRecord
+	address  string
+	location string
+}
+
+// readTerraformRecordSets finds the aws_route53_record blocks generated by
+// tfz53 in the .tf files of the directory. A block counts as generated when
+// its resource name is the one the naming strategy gives its name and type.
+// Blocks of excluded types are left out.
+func readTerraformRecordSets(dir string, excludedTypes map[uint16]bool, namer *resourceNamer, diag *diagnostics) (map[recordKey]terraformRecordSet, error) {
+	fileNames, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(fileNames)
+
+	sets := make(map[recordKey]terraformRecordSet)
+	for _, fileName := range fileNames {
+		src, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+		blocks, err := parseHCL(string(src), fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, block := range blocks {
+			if block.Type != "resource" || len(block.Labels) != 2 || block.Labels[0] != "aws_route53_record" {
+				continue
+			}
+			set, ok := terraformRecordSetFromBlock(block, namer, diag)
+			if !ok || excludedTypes[dns.StringToType[set.record.Type]] {
+				continue
+			}
+			set.location = fmt.Sprintf("%s:%d", fileName, block.Line)
+
+			key := recordKey{set.record.Name, set.record.Type}
+			if other, ok := sets[key]; ok {
+				return nil, fmt.Errorf("%s %s is defined both by %s in %s and %s in %s", key.Name, key.Type, other.address, other.location, set.address, set.location)
+			}
+			sets[key] = set
+		}
+	}
+	return sets, nil
+}
+
+func terraformRecordSetFromBlock(block hclBlock, namer *resourceNamer, diag *diagnostics) (terraformRecordSet, bool) {
+	rec, ok := recordFromBlock(block, diag)
+	if !ok || !namer.generatedName(recordKey{rec.Name, rec.Type}, block.Labels[1]) {
+		return terraformRecordSet{}, false
+	}
+	return terraformRecordSet{record: rec, address: "aws_route53_record." + block.Labels[1]}, true
+}
+
+// recordFromBlock reads the record set of an aws_route53_record block, which
+// needs a literal name and type. Blocks repeated with for_each or count, or
+// with a TTL or records that are not literals, such as references, cannot be
+// evaluated, and are reported rather than compared.
+func recordFromBlock(block hclBlock, diag *diagnostics) (dnsRecord, bool) {
+	address := fmt.Sprintf("aws_route53_record.%s", block.Labels[1])
+	for _, meta := range []string{"for_each", "count"} {
+		if _, ok := block.Attributes[meta]; ok {
+			diag.add(Warning, block.File, block.Line, "%s is repeated with %s, which cannot be evaluated, and is not compared", address, meta)
+			return dnsRecord{}, false
+		}
+	}
+	name, ok := block.Attributes["name"].literal()
+	if !ok {
+		diag.add(Warning, block.File, block.Line, "The name of %s is not a literal, and it is not compared", address)
+		return dnsRecord{}, false
+	}
+	rrType, ok := block.Attributes["type"].literal()
+	if !ok {
+		diag.add(Warning, block.File, block.Line, "The type of %s is not a literal, and it is not compared", address)
+		return dnsRecord{}, false
+	}
+	rec := dnsRecord{
+		Name: dns.Fqdn(strings.ToLower(name)),
+		Type: strings.ToUpper(rrType),
+	}
+
+	if attr, ok := block.Attributes["ttl"]; ok {
+		ttl, ok := attr.literal()
+		if !ok {
+			diag.add(Warning, block.File, block.Line, "The ttl of %s is not a literal, and it is not compared", address)
+			return dnsRecord{}, false
+		}
+		n, err := strconv.ParseUint(ttl, 10, 32)
+		if err != nil {
+			diag.add(Warning, block.File, block.Line, "Invalid ttl %q in %s", ttl, address)
+		}
+		rec.TTL = uint32(n)
+	}
+	if attr, ok := block.Attributes["records"]; ok {
+		values, ok := attr.literalList()
+		if !ok {
+			diag.add(Warning, block.File, block.Line, "The records of %s are not a list of literals, and it is not compared", address)
+			return dnsRecord{}, false
+		}
+		for _, value := range values {
+			rec.Data = append(rec.Data, terraformRecordData(rec.Type, value))
+		}
+	}
+	return rec, true
+}
+
+// terraformDiff compares the record sets generated by tfz53 in the
+// configuration with the zone records, as changes from the configuration to
+// the zone file. The character-strings of TXT values are compared as Route 53
+// receives them from the provider.
+func terraformDiff(sets map[recordKey]terraformRecordSet, records map[recordKey]dnsRecord) []recordSetChange {
+	configured := make(map[recordKey]dnsRecord, len(sets))
+	for key, set := range sets {
+		configured[key] = set.record
+	}
+	return zoneDelta(configured, records)
+}
+
+// writeTerraformDiff reports the changes, with the location of the resources
+// in the configuration.
+func writeTerraformDiff(w io.Writer, changes []recordSetChange, sets map[recordKey]terraformRecordSet) error {
+	var b strings.Builder
+	for _, c := range changes {
+		switch c.Kind {
+		case changeCreated:
+			fmt.Fprintf(&b, "added %s %s, missing from Terraform\n", c.Key.Name, c.Key.Type)
+		case changeDeleted:
+			set := sets[c.Key]
+			fmt.Fprintf(&b, "removed %s %s, %s at %s is not in the zone file\n", c.Key.Name, c.Key.Type, set.address, set.location)
+		case changeModified:
+			set := sets[c.Key]
+			fmt.Fprintf(&b, "changed %s %s, %s at %s differs from the zone file\n", c.Key.Name, c.Key.Type, set.address, set.location)
+		}
+		writeChangeRecords(&b, c)
+	}
+	if len(changes) == 0 {
+		b.WriteString("No differences\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
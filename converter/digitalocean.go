@@ -0,0 +1,156 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	digitalOceanZoneTemplateStr = `resource "digitalocean_domain" "{{ .ID }}" {
+  name = "{{ .Domain }}"
+}
+`
+	digitalOceanRecordTemplateStr = `{{- range .Record.Comments }}
+# {{ . }}{{ end }}
+resource "digitalocean_record" "{{ .ResourceID }}" {
+{{- range .Attributes }}
+  {{ .Key }} = {{ .Value }}
+{{- end }}
+}
+`
+)
+
+// digitalOceanMinTTL is the lowest TTL DigitalOcean accepts.
+const digitalOceanMinTTL = 30
+
+// digitalOceanSupportedTypes lists the record types DigitalOcean DNS accepts.
+var digitalOceanSupportedTypes = map[string]bool{
+	"A":     true,
+	"AAAA":  true,
+	"CAA":   true,
+	"CNAME": true,
+	"MX":    true,
+	"NS":    true,
+	"SRV":   true,
+	"TXT":   true,
+}
+
+// digitalOceanCAATags lists the CAA property tags DigitalOcean accepts.
+var digitalOceanCAATags = map[string]bool{
+	"issue":     true,
+	"issuewild": true,
+	"iodef":     true,
+}
+
+func init() {
+	registerProvider(func() providerTarget { return &digitalOceanTarget{} })
+}
+
+// digitalOceanTarget generates digitalocean_domain and digitalocean_record
+// resources, with one resource per value.
+type digitalOceanTarget struct{}
+
+func (t *digitalOceanTarget) String() string {
+	return "digitalocean"
+}
+
+func (t *digitalOceanTarget) templates() (string, string) {
+	return digitalOceanZoneTemplateStr, digitalOceanRecordTemplateStr
+}
+
+func (t *digitalOceanTarget) prepareZone(zone *zoneTemplateData) {}
+
+func (t *digitalOceanTarget) zoneReference(zoneID string) string {
+	return fmt.Sprintf("digitalocean_domain.%s.id", zoneID)
+}
+
+func (t *digitalOceanTarget) supportsType(rrType string) bool {
+	return digitalOceanSupportedTypes[rrType]
+}
+
+func (t *digitalOceanTarget) groupRecords(record dnsRecord, diag *diagnostics) []dnsRecord {
+	return groupByValue(record, diag)
+}
+
+func (t *digitalOceanTarget) recordName(name, domain string) string {
+	return relativeName(name, domain)
+}
+
+func (t *digitalOceanTarget) resourceID(setID string, record dnsRecord) string {
+	return valueResourceID(setID, record)
+}
+
+// encodeRecord rejects values DigitalOcean cannot represent, and raises TTLs
+// below the minimum with a warning.
+func (t *digitalOceanTarget) encodeRecord(data *recordTemplateData, diag *diagnostics) error {
+	record := data.Record
+	if record.Type == "CNAME" && data.Name == "@" {
+		return fmt.Errorf("DigitalOcean does not allow CNAME records at the zone apex, skipping %s", record.Name)
+	}
+
+	valueAttrs, err := digitalOceanRecordValue(record.Type, record.Data[0])
+	if err != nil {
+		return fmt.Errorf("%v, skipping value of %s", err, record.Name)
+	}
+
+	ttl := record.TTL
+	if ttl < digitalOceanMinTTL {
+		diag.recordWarnf(record, "DigitalOcean requires a TTL of at least %d, raising TTL of %s %s from %d", digitalOceanMinTTL, record.Name, record.Type, ttl)
+		ttl = digitalOceanMinTTL
+	}
+
+	attrs := []attribute{
+		{"domain", data.ZoneReference},
+		{"type", ensureQuoted(record.Type)},
+		{"name", ensureQuoted(data.Name)},
+	}
+	attrs = append(attrs, valueAttrs...)
+	attrs = append(attrs, attribute{"ttl", strconv.Itoa(int(ttl))})
+	data.Attributes = alignAttributes(attrs)
+	return nil
+}
+
+// digitalOceanRecordValue converts a single record value to the attributes of
+// a digitalocean_record, splitting priority, weight and port out of MX and SRV
+// data, and flags and tag out of CAA data. Host names keep their trailing dot,
+// which DigitalOcean requires for fully qualified targets.
+func digitalOceanRecordValue(rrType, value string) ([]attribute, error) {
+	switch rrType {
+	case "A", "AAAA", "CNAME", "NS":
+		return []attribute{{"value", ensureQuoted(value)}}, nil
+	case "TXT":
+		return []attribute{{"value", txtValue(value)}}, nil
+	}
+
+	rr, err := parseRecordData(rrType, value)
+	if err != nil {
+		return nil, err
+	}
+	switch rr := rr.(type) {
+	case *dns.MX:
+		return []attribute{
+			{"value", ensureQuoted(rr.Mx)},
+			{"priority", strconv.Itoa(int(rr.Preference))},
+		}, nil
+	case *dns.SRV:
+		return []attribute{
+			{"value", ensureQuoted(rr.Target)},
+			{"priority", strconv.Itoa(int(rr.Priority))},
+			{"weight", strconv.Itoa(int(rr.Weight))},
+			{"port", strconv.Itoa(int(rr.Port))},
+		}, nil
+	case *dns.CAA:
+		if !digitalOceanCAATags[rr.Tag] {
+			return nil, fmt.Errorf("DigitalOcean does not support CAA tag %q", rr.Tag)
+		}
+		return []attribute{
+			{"value", fmt.Sprintf("%q", rr.Value)},
+			{"flags", strconv.Itoa(int(rr.Flag))},
+			{"tag", ensureQuoted(rr.Tag)},
+		}, nil
+	default:
+		return nil, fmt.Errorf("Cannot create DigitalOcean record for %s data %q", rrType, value)
+	}
+}
@@ -0,0 +1,259 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// terraformRecord holds the attributes of an aws_route53_record instance in a
+// Terraform state or plan.
+type terraformRecord struct {
+	Address       string   `json:"-"`
+	ZoneID        string   `json:"zone_id"`
+	Name          string   `json:"name"`
+	Type          string   `json:"type"`
+	TTL           uint32   `json:"ttl"`
+	Records       []string `json:"records"`
+	SetIdentifier string   `json:"set_identifier"`
+	Alias         []struct {
+		Name                 string `json:"name"`
+		ZoneID               string `json:"zone_id"`
+		EvaluateTargetHealth bool   `json:"evaluate_target_health"`
+	} `json:"alias"`
+}
+
+// terraformZone holds the attributes of an aws_route53_zone resource or data
+// source instance.
+type terraformZone struct {
+	ZoneID string `json:"zone_id"`
+	Name   string `json:"name"`
+}
+
+type terraformModule struct {
+	Resources []struct {
+		Address string          `json:"address"`
+		Type    string          `json:"type"`
+		Values  json.RawMessage `json:"values"`
+	} `json:"resources"`
+	ChildModules []terraformModule `json:"child_modules"`
+}
+
+type terraformValues struct {
+	RootModule terraformModule `json:"root_module"`
+}
+
+// terraformResources collects the Route 53 records and zones of a state file,
+// or of the output of `terraform show -json` for a state or plan.
+type terraformResources struct {
+	records []terraformRecord
+	zones   []terraformZone
+}
+
+func readTerraformResources(r io.Reader) (*terraformResources, error) {
+	var doc struct {
+		// State files
+		Resources []struct {
+			Module    string `json:"module"`
+			Mode      string `json:"mode"`
+			Type      string `json:"type"`
+			Name      string `json:"name"`
+			Instances []struct {
+				IndexKey   interface{}     `json:"index_key"`
+				Attributes json.RawMessage `json:"attributes"`
+			} `json:"instances"`
+		} `json:"resources"`
+		// terraform show -json of a state, and of a plan
+		Values        *terraformValues `json:"values"`
+		PlannedValues *terraformValues `json:"planned_values"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	res := &terraformResources{}
+	for _, resource := range doc.Resources {
+		address := fmt.Sprintf("%s.%s", resource.Type, resource.Name)
+		if resource.Mode == "data" {
+			address = "data." + address
+		}
+		if resource.Module != "" {
+			address = resource.Module + "." + address
+		}
+		for _, instance := range resource.Instances {
+			instanceAddress := address
+			switch key := instance.IndexKey.(type) {
+			case string:
+				instanceAddress += fmt.Sprintf("[%q]", key)
+			case float64:
+				instanceAddress += fmt.Sprintf("[%d]", int(key))
+			}
+			if err := res.add(resource.Type, instanceAddress, instance.Attributes); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	values := doc.Values
+	if doc.PlannedValues != nil {
+		values = doc.PlannedValues
+	}
+	if values != nil {
+		if err := res.addModule(values.RootModule); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (res *terraformResources) addModule(module terraformModule) error {
+	for _, resource := range module.Resources {
+		if err := res.add(resource.Type, resource.Address, resource.Values); err != nil {
+			return err
+		}
+	}
+	for _, child := range module.ChildModules {
+		if err := res.addModule(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (res *terraformResources) add(resourceType, address string, attributes json.RawMessage) error {
+	switch resourceType {
+	case "aws_route53_record":
+		rec := terraformRecord{Address: address}
+		if err := json.Unmarshal(attributes, &rec); err != nil {
+			return fmt.Errorf("%s: %v", address, err)
+		}
+		res.records = append(res.records, rec)
+	case "aws_route53_zone":
+		var zone terraformZone
+		if err := json.Unmarshal(attributes, &zone); err != nil {
+			return fmt.Errorf("%s: %v", address, err)
+		}
+		res.zones = append(res.zones, zone)
+	}
+	return nil
+}
+
+// exportedRecordSet is a record set of the exported zone file, with the
+// addresses of the resources it was exported from.
+type exportedRecordSet struct {
+	addresses []string
+	lines     []string
+}
+
+// exportZone writes the aws_route53_record resources of the zone as a zone
+// file. The records are selected by the ID of the hosted zone, which when
+// empty is taken from the aws_route53_zone resources of the same name. Records
+// without a known zone, as in plans creating the zone, are selected by name.
+// Alias and routing policy records, which zone files cannot describe, are
+// written as comments.
+func exportZone(r io.Reader, domain, zoneID string, w io.Writer, diag *diagnostics) error {
+	res, err := readTerraformResources(r)
+	if err != nil {
+		return err
+	}
+
+	apex := dns.Fqdn(strings.ToLower(domain))
+	zoneIDs := make(map[string]bool)
+	if zoneID != "" {
+		zoneIDs[zoneID] = true
+	} else {
+		for _, zone := range res.zones {
+			if dns.Fqdn(strings.ToLower(zone.Name)) == apex && zone.ZoneID != "" {
+				zoneIDs[zone.ZoneID] = true
+			}
+		}
+	}
+
+	sets := make(map[recordKey]*exportedRecordSet)
+	for _, rec := range res.records {
+		if rec.ZoneID != "" && len(zoneIDs) > 0 {
+			if !zoneIDs[rec.ZoneID] {
+				continue
+			}
+		} else if !dns.IsSubDomain(apex, dns.Fqdn(strings.ToLower(rec.Name))) {
+			continue
+		}
+		name := terraformRecordName(rec.Name, apex)
+
+		key := recordKey{name, strings.ToUpper(rec.Type)}
+		set, ok := sets[key]
+		if !ok {
+			set = &exportedRecordSet{}
+			sets[key] = set
+		} else if rec.SetIdentifier == "" && len(rec.Alias) == 0 {
+			diag.warnf("%s %s is managed by several resources, merging the records of %s", key.Name, key.Type, rec.Address)
+		}
+		set.addresses = append(set.addresses, rec.Address)
+
+		if len(rec.Alias) > 0 {
+			alias := rec.Alias[0]
+			set.lines = append(set.lines, fmt.Sprintf("; alias %s %s -> %s (hosted zone %s, evaluate target health %t)",
+				key.Name, key.Type, dns.Fqdn(alias.Name), alias.ZoneID, alias.EvaluateTargetHealth))
+			continue
+		}
+		for _, value := range rec.Records {
+			line := fmt.Sprintf("%s %d IN %s %s", key.Name, rec.TTL, key.Type, terraformRecordData(key.Type, value))
+			rr, err := dns.NewRR(line)
+			if err != nil {
+				diag.warnf("Cannot export %s: %v", rec.Address, err)
+				set.lines = append(set.lines, "; invalid: "+line)
+				continue
+			}
+			line = rr.String()
+			if rec.SetIdentifier != "" {
+				line = fmt.Sprintf("; set identifier %s: %s", rec.SetIdentifier, line)
+			}
+			set.lines = append(set.lines, line)
+		}
+	}
+
+	keys := make(recordKeySlice, 0, len(sets))
+	for key := range sets {
+		keys = append(keys, key)
+	}
+	sort.Sort(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "; %s exported by tfz53 from Terraform\n$ORIGIN %s\n", strings.TrimRight(apex, "."), apex)
+	for _, key := range keys {
+		b.WriteString("\n")
+		for _, address := range sets[key].addresses {
+			fmt.Fprintf(&b, "; %s\n", address)
+		}
+		for _, line := range sets[key].lines {
+			b.WriteString(line + "\n")
+		}
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// terraformRecordName qualifies a record name the way the AWS provider does,
+// appending the zone name unless the name already ends with it.
+func terraformRecordName(name, apex string) string {
+	name = dns.Fqdn(strings.ToLower(unescapeRoute53Name(name)))
+	if !dns.IsSubDomain(apex, name) {
+		name = name + apex
+	}
+	return name
+}
+
+// terraformRecordData converts a value of the records attribute to zone file
+// notation, in the canonical form of the zone side. TXT and SPF values are
+// split into character-strings at the "" sequences the provider passes on to
+// Route 53.
+func terraformRecordData(rrType, value string) string {
+	if isTXTType(rrType) {
+		value = txtFromTerraform(value)
+	}
+	return canonicalValue(rrType, value)
+}
@@ -0,0 +1,486 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/idna"
+)
+
+// Syntax is the Terraform syntax resources are generated in.
+type Syntax uint8
+
+func (m Syntax) String() string {
+	switch m {
+	case Modern:
+		return "modern"
+	case Legacy:
+		return "legacy"
+	default:
+		panic("Unknown syntax")
+	}
+}
+
+const (
+	// Modern is the syntax of Terraform 0.12 and later.
+	Modern Syntax = iota
+	// Legacy is the syntax of Terraform versions older than 0.12.
+	Legacy
+)
+
+type configGenerator struct {
+	zoneTemplate   *template.Template
+	recordTemplate *template.Template
+
+	provider providerTarget
+	syntax   Syntax
+
+	// previous holds the resources of a previous generation, to move to the
+	// new resources of the same records. movedScript receives the moves in
+	// legacy syntax, which has no moved blocks.
+	previous    []previousResource
+	movedScript io.Writer
+
+	diag *diagnostics
+
+	// naming is the strategy naming the resources, and namer names those of
+	// the zone being generated.
+	naming string
+	namer  *resourceNamer
+}
+
+func newConfigGenerator(provider providerTarget, syntax Syntax, diag *diagnostics) *configGenerator {
+	g := &configGenerator{provider: provider, syntax: syntax, naming: defaultNamingStrategy, diag: diag}
+	funcs := template.FuncMap{
+		"ensureQuoted": ensureQuoted,
+		"reference":    g.reference,
+	}
+
+	zoneTemplate, recordTemplate := provider.templates()
+	g.zoneTemplate = template.Must(template.New("zone").Funcs(funcs).Parse(zoneTemplate))
+	g.recordTemplate = template.Must(template.New("record").Funcs(funcs).Parse(recordTemplate))
+	return g
+}
+
+type zoneTemplateData struct {
+	ID            string
+	Name          string
+	Domain        string
+	ResourceGroup string
+}
+type recordTemplateData struct {
+	ResourceID    string
+	ResourceType  string
+	Record        dnsRecord
+	ZoneID        string
+	ZoneReference string
+	Name          string
+
+	// The resource type and encoded values of the record, populated by the
+	// provider target. Values holds plain values for list attributes, while
+	// Attributes and Blocks hold structured values for providers that need
+	// them.
+	Values     []string
+	Attributes []attribute
+	Blocks     [][]attribute
+}
+type dnsRecord struct {
+	Name        string
+	Type        string
+	TTL         uint32
+	Data        []string
+	Comments    []string
+	Annotations []string
+
+	// File and Line locate the first record of the set in the zone file, for
+	// diagnostics. Line is 0 when not known.
+	File string
+	Line int
+}
+
+// attribute is a single attribute of a Terraform block, rendered by templates
+// for providers whose records are not plain value lists.
+type attribute struct {
+	Key   string
+	Value string
+}
+
+// alignAttributes pads the attribute keys of a block to equal width, like
+// terraform fmt does.
+func alignAttributes(attrs []attribute) []attribute {
+	width := 0
+	for _, a := range attrs {
+		if len(a.Key) > width {
+			width = len(a.Key)
+		}
+	}
+	for i := range attrs {
+		attrs[i].Key += strings.Repeat(" ", width-len(attrs[i].Key))
+	}
+	return attrs
+}
+
+type recordKey struct {
+	Name string
+	Type string
+}
+type recordKeySlice []recordKey
+
+func (records recordKeySlice) Len() int {
+	return len(records)
+}
+func (records recordKeySlice) Less(i, j int) bool {
+	genKey := func(k recordKey) string {
+		return fmt.Sprintf("%s-%s", k.Name, k.Type)
+	}
+	return genKey(records[i]) < genKey(records[j])
+}
+func (records recordKeySlice) Swap(i, j int) {
+	tmp := records[i]
+	records[i] = records[j]
+	records[j] = tmp
+}
+
+// zoneWriter writes the records of a zone in an output format other than
+// Terraform.
+type zoneWriter interface {
+	writeZone(domain string, records map[recordKey]dnsRecord, w io.Writer, diag *diagnostics) error
+}
+
+func (g *configGenerator) generateTerraformForZone(domain string, records map[recordKey]dnsRecord, output io.Writer) error {
+	namer, err := newResourceNamer(g.naming, domain)
+	if err != nil {
+		return err
+	}
+	if err := namer.assign(sortedRecordKeys(records), g.diag); err != nil {
+		return err
+	}
+	g.namer = namer
+	if err := namer.writeHeader(output); err != nil {
+		return err
+	}
+
+	zone, err := g.generateZoneResource(domain, output)
+	if err != nil {
+		return err
+	}
+
+	generated := make(map[string]dnsRecord)
+	for _, key := range sortedRecordKeys(records) {
+		rec := records[key]
+		if !g.provider.supportsType(rec.Type) {
+			g.diag.recordWarnf(rec, "%s does not support %s records, skipping %s", g.provider, rec.Type, rec.Name)
+			continue
+		}
+		err := g.generateRecordResource(rec, zone, output)
+		if err != nil {
+			g.diag.recordErrorf(rec, "%v", err)
+			continue
+		}
+		if g.previous != nil {
+			for _, group := range g.provider.groupRecords(rec, g.diag) {
+				generated[g.resourceID(group)] = group
+			}
+		}
+	}
+
+	if g.previous != nil {
+		return g.writeMoves(generated, output)
+	}
+	return nil
+}
+
+// sortedRecordKeys returns the keys of the records in the order their
+// resources are generated.
+func sortedRecordKeys(records map[recordKey]dnsRecord) recordKeySlice {
+	recordKeys := make(recordKeySlice, 0, len(records))
+	for key := range records {
+		recordKeys = append(recordKeys, key)
+	}
+	sort.Sort(sort.Reverse(recordKeys))
+	return recordKeys
+}
+
+// zoneFileName returns the file name, or when empty, the name of the file
+// the reader reads from, if any.
+func zoneFileName(r io.Reader, fileName string) string {
+	if f, ok := r.(*os.File); ok && fileName == "" {
+		return f.Name()
+	}
+	return fileName
+}
+
+// readZoneRecords parses the zone, relative to the origin, and merges its
+// records into record sets, resolving conflicting TTLs by the policy and
+// dropping duplicate values. Values are canonicalized and sorted. Records
+// that fail to parse are reported and skipped. The file name is used in the
+// errors, and taken from the reader when it is a file and no name is given.
+func readZoneRecords(zoneReader io.Reader, fileName, origin string, excludedTypes map[uint16]bool, policy ttlPolicy, diag *diagnostics) map[recordKey]dnsRecord {
+	fileName = zoneFileName(zoneReader, fileName)
+	records := make(map[recordKey]dnsRecord)
+	src, err := ioutil.ReadAll(zoneReader)
+	if err != nil {
+		diag.add(Error, fileName, 0, "%v", err)
+		return records
+	}
+	lines := recordLines(src)
+	ttls := newTTLSetReader(policy)
+
+	i := 0
+	for rr := range dns.ParseZone(bytes.NewReader(src), origin, fileName) {
+		if rr.Error != nil {
+			diag.parseError(rr.Error, fileName)
+			continue
+		}
+		line := 0
+		if i < len(lines) {
+			line = lines[i]
+		}
+		i++
+
+		record := generateRecord(rr)
+		record.File, record.Line = fileName, line
+
+		recordType := rr.Header().Rrtype
+		isExcluded, ok := excludedTypes[recordType]
+		if ok && isExcluded {
+			diag.recordInfof(record, "Excluding %s %s", record.Name, record.Type)
+			continue
+		}
+
+		key := recordKey{record.Name, record.Type}
+		if set, ok := records[key]; ok {
+			set.TTL = ttls.merge(key, set, record, diag)
+			data := record.Data[:0]
+			for _, value := range record.Data {
+				if containsString(set.Data, value) {
+					diag.recordWarnf(record, "Skipping duplicate %s value %s for %s", record.Type, value, record.Name)
+					continue
+				}
+				data = append(data, value)
+			}
+			record.Data = data
+			record = mergeRecords(set, record)
+		} else {
+			ttls.add(key, record)
+		}
+
+		records[key] = record
+	}
+
+	for key := range ttls.failed {
+		delete(records, key)
+	}
+	for _, record := range records {
+		sortValues(record.Data)
+	}
+	return records
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *configGenerator) generateZoneResource(domain string, w io.Writer) (zoneTemplateData, error) {
+	zoneName := strings.TrimRight(domain, ".")
+	data := zoneTemplateData{
+		ID:     strings.Replace(zoneName, ".", "-", -1),
+		Domain: zoneName,
+	}
+	data.Name = data.ID
+	g.provider.prepareZone(&data)
+
+	err := g.zoneTemplate.Execute(w, data)
+	return data, err
+}
+
+// generateRecordResource renders the resources for a record set. Providers
+// that do not group all values of a set into one resource get several.
+func (g *configGenerator) generateRecordResource(record dnsRecord, zone zoneTemplateData, w io.Writer) error {
+	var errs errorList
+	for _, group := range g.provider.groupRecords(record, g.diag) {
+		data := recordTemplateData{
+			ResourceID:    g.resourceID(group),
+			Record:        group,
+			ZoneID:        zone.ID,
+			ZoneReference: g.reference(g.provider.zoneReference(zone.ID)),
+			Name:          g.provider.recordName(group.Name, zone.Domain),
+		}
+		if err := g.provider.encodeRecord(&data, g.diag); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if err := g.recordTemplate.Execute(w, data); err != nil {
+			return err
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// resourceID returns the resource name of a record group, named by the
+// strategy of the zone being generated.
+func (g *configGenerator) resourceID(group dnsRecord) string {
+	setID := recordSetResourceID(group)
+	if g.namer != nil {
+		setID = g.namer.name(recordKey{group.Name, group.Type})
+	}
+	return g.provider.resourceID(setID, group)
+}
+
+func mergeRecords(a, b dnsRecord) dnsRecord {
+	a.Data = append(a.Data, b.Data...)
+	a.Comments = append(a.Comments, b.Comments...)
+	a.Annotations = append(a.Annotations, b.Annotations...)
+
+	return a
+}
+
+func generateRecord(rr *dns.Token) dnsRecord {
+	header := rr.Header()
+	name := strings.ToLower(header.Name)
+
+	key := recordKey{
+		Name: name,
+		Type: dns.TypeToString[header.Rrtype],
+	}
+
+	data := canonicalData(rr.RR)
+
+	comments := make([]string, 0)
+	if rr.Comment != "" {
+		comments = append(comments, strings.TrimLeft(rr.Comment, ";"))
+	}
+	return dnsRecord{
+		Name:        key.Name,
+		Type:        key.Type,
+		TTL:         header.Ttl,
+		Data:        []string{data},
+		Comments:    comments,
+		Annotations: parseAnnotations(rr.Comment),
+	}
+}
+
+// sanitizeRecordName creates a normalized record name that Terraform accepts.
+// Terraform only allows letters, numbers, dashes and underscores, while DNS
+// records allow far more.
+// 1. All dots are replaced with -
+// 2. * is replaced by the string "wildcard"
+// 3. IDN records are cleaned using punycode conversion
+// 4. Any remaining non-allowed characters are replaced underscore
+// 5. If the start of the record name is not a valid Terraform identifier,
+//    then prepend an underscore.
+func sanitizeRecordName(name string) string {
+	withoutDots := strings.Replace(strings.TrimRight(name, "."), ".", "-", -1)
+	withoutAsterisk := strings.Replace(withoutDots, "*", "wildcard", -1)
+
+	// Names that cannot be punycoded have their non-ASCII characters
+	// replaced below instead
+	punycoded, err := idna.Punycode.ToASCII(withoutAsterisk)
+	if err != nil {
+		punycoded = withoutAsterisk
+	}
+
+	id := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') ||
+			(r >= 'A' && r <= 'z') ||
+			(r >= '0' && r <= '9') ||
+			(r == '-' || r == '_') {
+			return r
+		}
+		return '_'
+	}, punycoded)
+
+	if (id[0] >= 'a' && id[0] <= 'z') ||
+		(id[0] >= 'A' && id[0] <= 'Z') ||
+		(id[0] == '_') {
+		return id
+	}
+
+	return fmt.Sprintf("_%s", id)
+}
+
+// relativeName returns the record name relative to the zone, using @ for the
+// zone apex. Names outside the zone are returned fully qualified.
+func relativeName(name, domain string) string {
+	name = dns.Fqdn(strings.ToLower(name))
+	domain = dns.Fqdn(strings.ToLower(domain))
+	if name == domain {
+		return "@"
+	}
+	if strings.HasSuffix(name, "."+domain) {
+		return strings.TrimSuffix(name, "."+domain)
+	}
+	return name
+}
+
+// annotationPrefix marks words in record comments that instruct tfz53 how to
+// treat the record, such as tfz53:proxied.
+const annotationPrefix = "tfz53:"
+
+func parseAnnotations(comment string) []string {
+	var annotations []string
+	for _, field := range strings.Fields(strings.TrimLeft(comment, ";")) {
+		if strings.HasPrefix(field, annotationPrefix) {
+			annotations = append(annotations, field)
+		}
+	}
+	return annotations
+}
+
+// hasAnnotation reports whether any line of the record set carries the given
+// annotation.
+func hasAnnotation(record dnsRecord, annotation string) bool {
+	for _, a := range record.Annotations {
+		if a == annotation {
+			return true
+		}
+	}
+	return false
+}
+
+func excludedTypesFromString(s string) map[uint16]bool {
+	excludedTypes := make(map[uint16]bool)
+	for _, t := range strings.Split(s, ",") {
+		t = strings.ToUpper(t) // ensure upper case
+		rrType := dns.StringToType[t]
+		excludedTypes[rrType] = true
+	}
+	return excludedTypes
+}
+
+func ensureQuoted(s string) string {
+	if s[0] == '"' && s[len(s)-1] == '"' {
+		return s
+	}
+	return fmt.Sprintf("%q", s)
+}
+
+// reference renders an expression referring to another resource's attribute
+// in the configured syntax.
+func (g *configGenerator) reference(expr string) string {
+	switch g.syntax {
+	case Modern:
+		return expr
+	case Legacy:
+		return fmt.Sprintf(`"${%s}"`, expr)
+	default:
+		panic(fmt.Sprintf("Unknown mode %v", g.syntax))
+	}
+}
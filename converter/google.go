@@ -0,0 +1,155 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	googleZoneTemplateStr = `resource "google_dns_managed_zone" "{{ .ID }}" {
+  name     = "{{ .Name }}"
+  dns_name = "{{ .Domain }}."
+}
+`
+	googleRecordTemplateStr = `{{- range .Record.Comments }}
+# {{ . }}{{ end }}
+resource "google_dns_record_set" "{{ .ResourceID }}" {
+  managed_zone = {{ .ZoneReference }}
+  name         = "{{ .Name }}"
+  type         = "{{ .Record.Type }}"
+  ttl          = {{ .Record.TTL }}
+  rrdatas      = [{{ range $idx, $elem := .Values }}{{ if $idx }}, {{ end }}{{ $elem }}{{ end }}]
+}
+`
+	googleNameServerTemplateStr = `
+resource "google_dns_record_set" "{{ .ResourceID }}" {
+  managed_zone = {{ .ZoneReference }}
+  name         = "{{ .Name }}"
+  type         = "NS"
+  ttl          = {{ .TTL }}
+  rrdatas      = {{ .NameServers }}
+}
+`
+)
+
+func init() {
+	registerProvider(func() providerTarget { return &googleTarget{} })
+}
+
+// googleTarget generates google_dns_managed_zone and google_dns_record_set
+// resources for Google Cloud DNS.
+type googleTarget struct{}
+
+func (t *googleTarget) String() string {
+	return "google"
+}
+
+func (t *googleTarget) templates() (string, string) {
+	return googleZoneTemplateStr, googleRecordTemplateStr
+}
+
+func (t *googleTarget) prepareZone(zone *zoneTemplateData) {
+	zone.Name = googleZoneName(zone.ID)
+}
+
+func (t *googleTarget) zoneReference(zoneID string) string {
+	return fmt.Sprintf("google_dns_managed_zone.%s.name", zoneID)
+}
+
+func (t *googleTarget) supportsType(rrType string) bool {
+	return googleSupportedTypes[rrType]
+}
+
+func (t *googleTarget) groupRecords(record dnsRecord, diag *diagnostics) []dnsRecord {
+	return groupByRecordSet(record, diag)
+}
+
+// recordName returns the fully qualified name, including the trailing dot
+// Cloud DNS requires.
+func (t *googleTarget) recordName(name, domain string) string {
+	return dns.Fqdn(name)
+}
+
+func (t *googleTarget) resourceID(setID string, record dnsRecord) string {
+	return setID
+}
+
+func (t *googleTarget) encodeRecord(data *recordTemplateData, diag *diagnostics) error {
+	for _, d := range data.Record.Data {
+		data.Values = append(data.Values, googleRRData(data.Record.Type, d))
+	}
+	return nil
+}
+
+func (t *googleTarget) nameServers(zoneID string) string {
+	return fmt.Sprintf("google_dns_managed_zone.%s.name_servers", zoneID)
+}
+
+// nameServerTemplate renders the apex NS record set as a regular record set,
+// as the Google provider replaces the one Cloud DNS creates with the zone in
+// place.
+func (t *googleTarget) nameServerTemplate() string {
+	return googleNameServerTemplateStr
+}
+
+func (t *googleTarget) handlingNotes(record dnsRecord) []string {
+	if record.Type == "TXT" || record.Type == "SPF" {
+		return []string{"character-strings are quoted, since Cloud DNS splits unquoted data on spaces"}
+	}
+	return nil
+}
+
+// googleSupportedTypes lists the record types Cloud DNS accepts in a
+// managed zone.
+var googleSupportedTypes = map[string]bool{
+	"A":        true,
+	"AAAA":     true,
+	"CAA":      true,
+	"CNAME":    true,
+	"DNSKEY":   true,
+	"DS":       true,
+	"IPSECKEY": true,
+	"MX":       true,
+	"NAPTR":    true,
+	"NS":       true,
+	"PTR":      true,
+	"SOA":      true,
+	"SPF":      true,
+	"SRV":      true,
+	"SSHFP":    true,
+	"TLSA":     true,
+	"TXT":      true,
+}
+
+// googleZoneName creates a managed zone name that Cloud DNS accepts. Zone
+// names may only contain lower case letters, numbers and dashes, must start
+// with a letter and may be at most 63 characters long.
+func googleZoneName(id string) string {
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(id))
+
+	if name == "" || name[0] < 'a' || name[0] > 'z' {
+		name = "zone-" + name
+	}
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return strings.TrimRight(name, "-")
+}
+
+// googleRRData renders a record value as a Cloud DNS rrdata string. Unlike
+// Route 53, Cloud DNS splits TXT data on spaces unless it is quoted, so TXT
+// and SPF values keep the quotes of their character-strings, which is how
+// Cloud DNS represents values longer than 255 characters.
+func googleRRData(rrType, data string) string {
+	if !isTXTType(rrType) {
+		return ensureQuoted(data)
+	}
+	return terraformString(data)
+}
@@ -0,0 +1,109 @@
+package converter
+
+import (
+	"strconv"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// hclBlock is a block of a Terraform configuration file, such as a resource,
+// as parsed by hashicorp/hcl. Attributes are evaluated without variables or
+// functions, so only those written as literals have a value, while any other
+// expression, such as a reference or a for expression, does not.
+type hclBlock struct {
+	Type       string
+	Labels     []string
+	Attributes map[string]hclValue
+	Blocks     []hclBlock
+	File       string
+	Line       int
+}
+
+// hclValue is the expression of an attribute.
+type hclValue struct {
+	Expr hclsyntax.Expression
+}
+
+// value evaluates the expression, which fails unless it is made of literals.
+func (v hclValue) value() (cty.Value, bool) {
+	if v.Expr == nil {
+		return cty.NilVal, false
+	}
+	val, diags := v.Expr.Value(nil)
+	if diags.HasErrors() || !val.IsWhollyKnown() || val.IsNull() {
+		return cty.NilVal, false
+	}
+	return val, true
+}
+
+// literal returns the value of a string literal, or a number or boolean as
+// written.
+func (v hclValue) literal() (string, bool) {
+	val, ok := v.value()
+	if !ok {
+		return "", false
+	}
+	return primitiveString(val)
+}
+
+// literalList returns the values of a list of literals.
+func (v hclValue) literalList() ([]string, bool) {
+	val, ok := v.value()
+	if !ok || !(val.Type().IsTupleType() || val.Type().IsListType()) {
+		return nil, false
+	}
+	values := make([]string, 0, val.LengthInt())
+	for it := val.ElementIterator(); it.Next(); {
+		_, item := it.Element()
+		s, ok := primitiveString(item)
+		if !ok {
+			return nil, false
+		}
+		values = append(values, s)
+	}
+	return values, true
+}
+
+func primitiveString(val cty.Value) (string, bool) {
+	if val.IsNull() {
+		return "", false
+	}
+	switch val.Type() {
+	case cty.String:
+		return val.AsString(), true
+	case cty.Number:
+		return val.AsBigFloat().Text('f', -1), true
+	case cty.Bool:
+		return strconv.FormatBool(val.True()), true
+	}
+	return "", false
+}
+
+// parseHCL parses the blocks of a configuration file.
+func parseHCL(src, fileName string) ([]hclBlock, error) {
+	file, diags := hclsyntax.ParseConfig([]byte(src), fileName, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	return convertHCLBlocks(file.Body.(*hclsyntax.Body).Blocks), nil
+}
+
+func convertHCLBlocks(blocks hclsyntax.Blocks) []hclBlock {
+	converted := make([]hclBlock, len(blocks))
+	for i, block := range blocks {
+		converted[i] = hclBlock{
+			Type:       block.Type,
+			Labels:     block.Labels,
+			Attributes: make(map[string]hclValue, len(block.Body.Attributes)),
+			Blocks:     convertHCLBlocks(block.Body.Blocks),
+			File:       block.TypeRange.Filename,
+			Line:       block.TypeRange.Start.Line,
+		}
+		for name, attr := range block.Body.Attributes {
+			converted[i].Attributes[name] = hclValue{attr.Expr}
+		}
+	}
+	return converted
+}
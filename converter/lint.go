@@ -0,0 +1,398 @@
+package converter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// defaultLintMinTTL is the lowest TTL the low-ttl rule accepts by default.
+const defaultLintMinTTL = 60
+
+// lintRule checks the record sets of a zone for a kind of problem. Rules are
+// identified by their ID in reports and configuration, and report findings
+// at their default severity unless configured otherwise. Security rules
+// grade their findings for code scanning tools by securitySeverity, a CVSS
+// score.
+type lintRule struct {
+	id               string
+	severity         Severity
+	securitySeverity string
+	description      string
+	check            func(z *lintZone, report lintReporter)
+}
+
+// lintReporter reports a finding of a rule at the first line of the record
+// set, or of the records the finding is about.
+type lintReporter func(rec dnsRecord, fix, format string, args ...interface{})
+
+// lintRules are the rules of tfz53 lint, in the order they are run.
+var lintRules = []lintRule{
+	{
+		id:          "cname-and-other-data",
+		severity:    Error,
+		description: "A name with a CNAME record has no other records, and a single CNAME value",
+		check:       lintCNAMEAndOtherData,
+	},
+	{
+		id:          "out-of-zone",
+		severity:    Error,
+		description: "Record names are within the zone",
+		check:       lintOutOfZone,
+	},
+	{
+		id:          "target-is-cname",
+		severity:    Warning,
+		description: "MX, NS and SRV records point at names with addresses, not CNAME records",
+		check:       lintTargetIsCNAME,
+	},
+	{
+		id:          "wildcard-shadowed",
+		severity:    Warning,
+		description: "Names next to a wildcard have the types of the wildcard, which does not apply to them",
+		check:       lintWildcardShadowed,
+	},
+	{
+		id:          "empty-non-terminal",
+		severity:    Info,
+		description: "Names with records below them have records of their own",
+		check:       lintEmptyNonTerminal,
+	},
+	{
+		id:          "low-ttl",
+		severity:    Warning,
+		description: "TTLs are at least the minimum TTL",
+		check:       lintLowTTL,
+	},
+	{
+		id:          "unresolvable-target",
+		severity:    Warning,
+		description: "Targets of CNAME, MX, NS and SRV records in the zone or its siblings exist, and have addresses where required",
+		check:       lintUnresolvableTarget,
+	},
+	{
+		id:          "cname-loop",
+		severity:    Error,
+		description: "CNAME records followed from a target do not loop",
+		check:       lintCNAMELoop,
+	},
+	{
+		id:               "subdomain-takeover",
+		severity:         Error,
+		securitySeverity: "8.0",
+		description:      "CNAME records and aliases do not point at services prone to subdomain takeover",
+		check:            lintSubdomainTakeover,
+	},
+}
+
+// lintZone is the zone under lint, with its record sets indexed by name.
+type lintZone struct {
+	apex    string
+	minTTL  uint32
+	records map[recordKey]dnsRecord
+	keys    recordKeySlice
+	// names maps each name with records to their sets, in order of type.
+	names map[string][]dnsRecord
+	// nonTerminals maps the names within the zone that have no records,
+	// but names below them do, to the first record set below them.
+	nonTerminals map[string]dnsRecord
+	// siblings are the other zones of the batch, which targets outside the
+	// zone are resolved in.
+	siblings []*lintZone
+	// aliases are the alias records described in comments, and takeover the
+	// suffixes of services prone to subdomain takeover.
+	aliases  []dnsRecord
+	takeover []takeoverSuffix
+}
+
+func newLintZone(domain string, minTTL uint32, records map[recordKey]dnsRecord) *lintZone {
+	z := &lintZone{
+		apex:         dns.Fqdn(strings.ToLower(domain)),
+		minTTL:       minTTL,
+		records:      records,
+		keys:         make(recordKeySlice, 0, len(records)),
+		names:        make(map[string][]dnsRecord),
+		nonTerminals: make(map[string]dnsRecord),
+	}
+	for key := range records {
+		z.keys = append(z.keys, key)
+	}
+	sort.Sort(z.keys)
+	for _, key := range z.keys {
+		z.names[key.Name] = append(z.names[key.Name], records[key])
+	}
+	for _, key := range z.keys {
+		if !z.inZone(key.Name) {
+			continue
+		}
+		for name := parentName(key.Name); name != "" && z.inZone(name); name = parentName(name) {
+			if _, ok := z.names[name]; ok {
+				break
+			}
+			if _, ok := z.nonTerminals[name]; !ok {
+				z.nonTerminals[name] = records[key]
+			}
+		}
+	}
+	return z
+}
+
+func (z *lintZone) inZone(name string) bool {
+	return dns.IsSubDomain(z.apex, name)
+}
+
+// exists returns whether the name has records, or names below it do.
+func (z *lintZone) exists(name string) bool {
+	if _, ok := z.names[name]; ok {
+		return true
+	}
+	_, ok := z.nonTerminals[name]
+	return ok
+}
+
+// has returns whether the name has a record set of the type.
+func (z *lintZone) has(name, rrType string) bool {
+	_, ok := z.records[recordKey{name, rrType}]
+	return ok
+}
+
+// parentName returns the name without its first label, or "" for the root.
+func parentName(name string) string {
+	if name == "." {
+		return ""
+	}
+	labels := dns.SplitDomainName(name)
+	if len(labels) <= 1 {
+		return "."
+	}
+	return dns.Fqdn(strings.Join(labels[1:], "."))
+}
+
+// recordTargets returns the names the values of a CNAME, MX, NS or SRV record
+// set point at, leaving out the root, which MX and SRV use for no service.
+func recordTargets(rec dnsRecord) []string {
+	var targets []string
+	for _, d := range rec.Data {
+		fields := strings.Fields(d)
+		var target string
+		switch {
+		case (rec.Type == "CNAME" || rec.Type == "NS") && len(fields) == 1:
+			target = fields[0]
+		case rec.Type == "MX" && len(fields) == 2:
+			target = fields[1]
+		case rec.Type == "SRV" && len(fields) == 4:
+			target = fields[3]
+		}
+		if target != "" && target != "." {
+			targets = append(targets, strings.ToLower(target))
+		}
+	}
+	return targets
+}
+
+func lintCNAMEAndOtherData(z *lintZone, report lintReporter) {
+	for _, key := range z.keys {
+		if key.Type != "CNAME" {
+			continue
+		}
+		rec := z.records[key]
+		if len(rec.Data) > 1 {
+			report(rec, "keep a single CNAME value", "%s has %d CNAME values", rec.Name, len(rec.Data))
+		}
+		var others []string
+		for _, set := range z.names[key.Name] {
+			if set.Type != "CNAME" {
+				others = append(others, set.Type)
+			}
+		}
+		if len(others) > 0 {
+			report(rec, "remove the CNAME record, or the other records", "%s has a CNAME record and %s records", rec.Name, strings.Join(others, ", "))
+		}
+	}
+}
+
+func lintOutOfZone(z *lintZone, report lintReporter) {
+	for _, key := range z.keys {
+		if !z.inZone(key.Name) {
+			report(z.records[key], "remove it, or move it to the zone of its name", "%s %s is outside the zone %s", key.Name, key.Type, z.apex)
+		}
+	}
+}
+
+func lintTargetIsCNAME(z *lintZone, report lintReporter) {
+	for _, key := range z.keys {
+		if key.Type != "MX" && key.Type != "NS" && key.Type != "SRV" {
+			continue
+		}
+		rec := z.records[key]
+		for _, target := range recordTargets(rec) {
+			if res := z.resolve(target); len(res.chain) > 1 {
+				report(rec, "point it at the target of the CNAME record", "%s %s points at %s, which is a CNAME record", rec.Name, rec.Type, target)
+			}
+		}
+	}
+}
+
+// lintWildcardShadowed reports names next to a wildcard that lack types of
+// the wildcard, as a wildcard only applies to names that do not exist, so
+// that the names do not get the records the wildcard provides to others.
+func lintWildcardShadowed(z *lintZone, report lintReporter) {
+	for _, key := range z.keys {
+		if !strings.HasPrefix(key.Name, "*.") || key.Type == "CNAME" {
+			continue
+		}
+		wildcard := z.records[key]
+		parent := parentName(key.Name)
+		var siblings []string
+		for name := range z.names {
+			if name != key.Name && parentName(name) == parent {
+				siblings = append(siblings, name)
+			}
+		}
+		for name := range z.nonTerminals {
+			if parentName(name) == parent {
+				siblings = append(siblings, name)
+			}
+		}
+		sort.Strings(siblings)
+		for _, name := range siblings {
+			if z.has(name, key.Type) || z.has(name, "CNAME") {
+				continue
+			}
+			rec, ok := z.nonTerminals[name]
+			if !ok {
+				rec = z.names[name][0]
+			}
+			report(rec, fmt.Sprintf("add the %s record to %s", key.Type, name),
+				"%s has no %s record, as it shadows the wildcard %s%s", name, key.Type, wildcard.Name, atLine(wildcard.Line))
+		}
+	}
+}
+
+func lintEmptyNonTerminal(z *lintZone, report lintReporter) {
+	names := make([]string, 0, len(z.nonTerminals))
+	for name := range z.nonTerminals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		rec := z.nonTerminals[name]
+		report(rec, "", "%s has no records, but exists as a parent of %s", name, rec.Name)
+	}
+}
+
+func lintLowTTL(z *lintZone, report lintReporter) {
+	for _, key := range z.keys {
+		rec := z.records[key]
+		if rec.TTL < z.minTTL {
+			report(rec, fmt.Sprintf("raise the TTL to at least %d", z.minTTL), "TTL %d of %s %s is below %d", rec.TTL, rec.Name, rec.Type, z.minTTL)
+		}
+	}
+}
+
+// lintRuleSeverities returns the severity of each rule, with the configured
+// overrides applied. Rules turned off are left out.
+func lintRuleSeverities(config map[string]string) (map[string]Severity, error) {
+	severities := make(map[string]Severity, len(lintRules))
+	for _, rule := range lintRules {
+		severities[rule.id] = rule.severity
+	}
+	for id, level := range config {
+		if _, ok := severities[id]; !ok {
+			return nil, fmt.Errorf("Unknown lint rule %q", id)
+		}
+		if level == "off" {
+			delete(severities, id)
+			continue
+		}
+		severity, err := severityFromString(level)
+		if err != nil {
+			return nil, fmt.Errorf("Lint rule %s: %v", id, err)
+		}
+		severities[id] = severity
+	}
+	return severities, nil
+}
+
+// lintZoneRecords runs the enabled rules over the record sets of the zone,
+// returning the findings ordered by their line in the zone file.
+func lintZoneRecords(z *lintZone, severities map[string]Severity, strict bool) []Diagnostic {
+	findings := &diagnostics{strict: strict}
+	for _, rule := range lintRules {
+		severity, ok := severities[rule.id]
+		if !ok {
+			continue
+		}
+		id := rule.id
+		rule.check(z, func(rec dnsRecord, fix, format string, args ...interface{}) {
+			findings.fixf(severity, rec.File, rec.Line, fix, format, args...)
+			findings.list[len(findings.list)-1].Rule = id
+		})
+	}
+	sort.SliceStable(findings.list, func(i, j int) bool {
+		return findings.list[i].Line < findings.list[j].Line
+	})
+	return findings.list
+}
+
+// writeLint writes the findings in the given format: text, json or sarif.
+func writeLint(w io.Writer, format string, findings []Diagnostic, severities map[string]Severity) error {
+	switch format {
+	case "text":
+		return writeLintText(w, findings)
+	case "json":
+		return writeLintJSON(w, findings)
+	case "sarif":
+		return writeSARIF(w, findings, severities)
+	default:
+		return fmt.Errorf("Unknown lint format %q, must be one of text, json, sarif", format)
+	}
+}
+
+func writeLintText(w io.Writer, findings []Diagnostic) error {
+	var b strings.Builder
+	counts := make(map[Severity]int)
+	for _, f := range findings {
+		counts[f.Severity]++
+		fmt.Fprintln(&b, f)
+	}
+	if len(findings) == 0 {
+		b.WriteString("No findings\n")
+	} else {
+		fmt.Fprintf(&b, "%d errors, %d warnings, %d info\n", counts[Error], counts[Warning], counts[Info])
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeLintJSON(w io.Writer, findings []Diagnostic) error {
+	items := make([]interface{}, len(findings))
+	counts := make(map[Severity]int)
+	for i, f := range findings {
+		counts[f.Severity]++
+		item := yamlMap{
+			{"rule", f.Rule},
+			{"severity", f.Severity.String()},
+		}
+		if f.File != "" {
+			item = append(item, yamlField{"file", f.File})
+		}
+		if f.Line > 0 {
+			item = append(item, yamlField{"line", f.Line})
+		}
+		item = append(item, yamlField{"message", f.Message})
+		if f.Fix != "" {
+			item = append(item, yamlField{"fix", f.Fix})
+		}
+		items[i] = item
+	}
+	return writeJSON(w, yamlMap{
+		{"findings", items},
+		{"errors", counts[Error]},
+		{"warnings", counts[Warning]},
+		{"info", counts[Info]},
+	})
+}
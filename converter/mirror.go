@@ -0,0 +1,260 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/miekg/dns"
+)
+
+// defaultNameServerTTL is used for the combined apex NS record set when the
+// zone file does not contain one. It matches the TTL Route 53 uses.
+const defaultNameServerTTL = 172800
+
+// mirrorTarget is implemented by provider targets that can serve a zone
+// together with another provider. Both sides of a mirrored zone get an apex
+// NS record set listing the name servers of both providers, unless the
+// provider cannot replace the one it creates with the zone.
+type mirrorTarget interface {
+	providerTarget
+	// nameServers returns the expression listing the fully qualified name
+	// servers of the zone.
+	nameServers(zoneID string) string
+	// nameServerTemplate returns the template rendering the apex NS record
+	// set from a nameServerTemplateData, or "" when the apex NS record set the
+	// provider creates with the zone cannot be replaced.
+	nameServerTemplate() string
+	// handlingNotes describes provider specific handling of the record, for
+	// the consistency report.
+	handlingNotes(record dnsRecord) []string
+}
+
+type nameServerTemplateData struct {
+	ResourceID    string
+	ZoneID        string
+	ZoneReference string
+	Name          string
+	TTL           uint32
+	NameServers   string
+}
+
+type mirrorReportEntry struct {
+	Key   recordKey
+	Notes []string
+}
+
+// mirroredGenerator generates the same zone for two providers from a single
+// parse, keeping the record sets on both sides identical.
+type mirroredGenerator struct {
+	primary, secondary             *configGenerator
+	primaryTarget, secondaryTarget mirrorTarget
+	primaryNS, secondaryNS         *template.Template
+
+	// naming is the strategy naming the resources on both sides.
+	naming string
+}
+
+func newMirroredGenerator(primary, secondary providerTarget, syntax Syntax, diag *diagnostics) (*mirroredGenerator, error) {
+	p, ok := primary.(mirrorTarget)
+	if !ok {
+		return nil, fmt.Errorf("Provider %s cannot be mirrored", primary)
+	}
+	s, ok := secondary.(mirrorTarget)
+	if !ok {
+		return nil, fmt.Errorf("Provider %s cannot be mirrored", secondary)
+	}
+	if p.String() == s.String() {
+		return nil, fmt.Errorf("Cannot mirror provider %s to itself", p)
+	}
+
+	m := &mirroredGenerator{
+		primary:         newConfigGenerator(p, syntax, diag),
+		secondary:       newConfigGenerator(s, syntax, diag),
+		primaryTarget:   p,
+		secondaryTarget: s,
+		naming:          defaultNamingStrategy,
+	}
+	funcs := template.FuncMap{
+		"reference": m.primary.reference,
+	}
+	if t := p.nameServerTemplate(); t != "" {
+		m.primaryNS = template.Must(template.New("ns").Funcs(funcs).Parse(t))
+	}
+	if t := s.nameServerTemplate(); t != "" {
+		m.secondaryNS = template.Must(template.New("ns").Funcs(funcs).Parse(t))
+	}
+	return m, nil
+}
+
+// generateTerraformForZone writes the resources of both providers to output,
+// and a report of every record that needed provider specific handling to
+// report. Records that either provider cannot represent are left out on both
+// sides. The apex NS record set of the zone file is replaced by one combining
+// the name servers of both providers, on the sides that can replace the one
+// created with the zone.
+func (m *mirroredGenerator) generateTerraformForZone(domain string, records map[recordKey]dnsRecord, output, report io.Writer) error {
+	// Both sides name their resources alike, including the combined apex NS
+	// record set
+	namer, err := newResourceNamer(m.naming, domain)
+	if err != nil {
+		return err
+	}
+	apexNS := recordKey{dns.Fqdn(strings.ToLower(domain)), "NS"}
+	keys := sortedRecordKeys(records)
+	if _, ok := records[apexNS]; !ok {
+		keys = append(keys, apexNS)
+	}
+	if err := namer.assign(keys, m.primary.diag); err != nil {
+		return err
+	}
+	m.primary.namer, m.secondary.namer = namer, namer
+	if err := namer.writeHeader(output); err != nil {
+		return err
+	}
+
+	var primaryOut, secondaryOut bytes.Buffer
+	primaryZone, err := m.primary.generateZoneResource(domain, &primaryOut)
+	if err != nil {
+		return err
+	}
+	secondaryZone, err := m.secondary.generateZoneResource(domain, &secondaryOut)
+	if err != nil {
+		return err
+	}
+
+	apex := dns.Fqdn(strings.ToLower(primaryZone.Domain))
+	nameServerTTL := uint32(defaultNameServerTTL)
+	nameServers := m.primary.reference(fmt.Sprintf("concat(%s, %s)",
+		m.primaryTarget.nameServers(primaryZone.ID), m.secondaryTarget.nameServers(secondaryZone.ID)))
+
+	var entries []mirrorReportEntry
+	for _, key := range sortedRecordKeys(records) {
+		rec := records[key]
+		if key.Name == apex && key.Type == "NS" {
+			nameServerTTL = rec.TTL
+			entries = append(entries, mirrorReportEntry{key, m.apexNameServerNotes()})
+			continue
+		}
+
+		notes, ok := m.generateRecord(rec, primaryZone, secondaryZone, &primaryOut, &secondaryOut)
+		if len(notes) > 0 {
+			entries = append(entries, mirrorReportEntry{key, notes})
+		}
+		if !ok {
+			m.primary.diag.recordWarnf(rec, "Skipping %s %s, which cannot be mirrored", rec.Name, rec.Type)
+		}
+	}
+
+	for _, side := range []struct {
+		g    *configGenerator
+		t    *template.Template
+		zone zoneTemplateData
+		out  *bytes.Buffer
+	}{
+		{m.primary, m.primaryNS, primaryZone, &primaryOut},
+		{m.secondary, m.secondaryNS, secondaryZone, &secondaryOut},
+	} {
+		if side.t == nil {
+			continue
+		}
+		data := nameServerTemplateData{
+			ResourceID:    namer.name(apexNS),
+			ZoneID:        side.zone.ID,
+			ZoneReference: side.g.reference(side.g.provider.zoneReference(side.zone.ID)),
+			Name:          side.g.provider.recordName(apex, side.zone.Domain),
+			TTL:           nameServerTTL,
+			NameServers:   nameServers,
+		}
+		if err := side.t.Execute(side.out, data); err != nil {
+			return err
+		}
+	}
+
+	if _, err := primaryOut.WriteTo(output); err != nil {
+		return err
+	}
+	fmt.Fprintln(output)
+	if _, err := secondaryOut.WriteTo(output); err != nil {
+		return err
+	}
+
+	if _, ok := records[apexNS]; !ok && (m.primaryNS == nil || m.secondaryNS == nil) {
+		entries = append(entries, mirrorReportEntry{apexNS, m.apexNameServerNotes()})
+	}
+	writeMirrorReport(report, m.primaryTarget, m.secondaryTarget, entries)
+	return nil
+}
+
+// apexNameServerNotes describes the apex NS record set of both sides, for the
+// consistency report.
+func (m *mirroredGenerator) apexNameServerNotes() []string {
+	if m.primaryNS != nil && m.secondaryNS != nil {
+		return []string{"replaced by the combined name servers of both providers"}
+	}
+	var notes []string
+	for _, side := range []struct {
+		t  mirrorTarget
+		ns *template.Template
+	}{
+		{m.primaryTarget, m.primaryNS},
+		{m.secondaryTarget, m.secondaryNS},
+	} {
+		if side.ns != nil {
+			notes = append(notes, fmt.Sprintf("%s: replaced by the combined name servers of both providers", side.t))
+		} else {
+			notes = append(notes, fmt.Sprintf("%s: left as created with the zone, listing only its own name servers, as it cannot be replaced", side.t))
+		}
+	}
+	return notes
+}
+
+// generateRecord renders the record for both providers, and only writes it
+// out when both succeed. It returns the notes for the consistency report and
+// whether the record was written.
+func (m *mirroredGenerator) generateRecord(rec dnsRecord, primaryZone, secondaryZone zoneTemplateData, primaryOut, secondaryOut io.Writer) ([]string, bool) {
+	var notes []string
+	for _, t := range []mirrorTarget{m.primaryTarget, m.secondaryTarget} {
+		if !t.supportsType(rec.Type) {
+			notes = append(notes, fmt.Sprintf("rejected, %s does not support %s records", t, rec.Type))
+		}
+	}
+	if len(notes) > 0 {
+		return notes, false
+	}
+
+	var primaryBuf, secondaryBuf bytes.Buffer
+	if err := m.primary.generateRecordResource(rec, primaryZone, &primaryBuf); err != nil {
+		notes = append(notes, fmt.Sprintf("rejected, %s: %v", m.primaryTarget, err))
+	}
+	if err := m.secondary.generateRecordResource(rec, secondaryZone, &secondaryBuf); err != nil {
+		notes = append(notes, fmt.Sprintf("rejected, %s: %v", m.secondaryTarget, err))
+	}
+	if len(notes) > 0 {
+		return notes, false
+	}
+
+	for _, t := range []mirrorTarget{m.primaryTarget, m.secondaryTarget} {
+		for _, note := range t.handlingNotes(rec) {
+			notes = append(notes, fmt.Sprintf("%s: %s", t, note))
+		}
+	}
+	primaryBuf.WriteTo(primaryOut)
+	secondaryBuf.WriteTo(secondaryOut)
+	return notes, true
+}
+
+func writeMirrorReport(w io.Writer, primary, secondary providerTarget, entries []mirrorReportEntry) {
+	fmt.Fprintf(w, "Mirror consistency report for %s and %s\n", primary, secondary)
+	if len(entries) == 0 {
+		fmt.Fprintln(w, "No records needed provider specific handling")
+		return
+	}
+	for _, e := range entries {
+		for _, note := range e.Notes {
+			fmt.Fprintf(w, "%s %s: %s\n", e.Key.Name, e.Key.Type, note)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+const route53RecordResource = "aws_route53_record"
+
+// previousResource is a record resource of a previous generation.
+type previousResource struct {
+	address string
+	record  dnsRecord
+}
+
+type resourceMove struct {
+	from, to string
+}
+
+// readPreviousResources reads the aws_route53_record resources of a
+// previously generated file, or of a state file or JSON state, named path.
+func readPreviousResources(src []byte, path, domain string, diag *diagnostics) ([]previousResource, error) {
+	var previous []previousResource
+	if bytes.HasPrefix(bytes.TrimSpace(src), []byte("{")) {
+		res, err := readTerraformResources(bytes.NewReader(src))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		apex := dns.Fqdn(strings.ToLower(domain))
+		for _, rec := range res.records {
+			if len(rec.Alias) > 0 || rec.SetIdentifier != "" {
+				continue
+			}
+			record := dnsRecord{
+				Name: terraformRecordName(rec.Name, apex),
+				Type: strings.ToUpper(rec.Type),
+				TTL:  rec.TTL,
+			}
+			for _, value := range rec.Records {
+				record.Data = append(record.Data, terraformRecordData(record.Type, value))
+			}
+			previous = append(previous, previousResource{rec.Address, record})
+		}
+		return previous, nil
+	}
+
+	blocks, err := parseHCL(string(src), path)
+	if err != nil {
+		return nil, err
+	}
+	for _, block := range blocks {
+		if block.Type != "resource" || len(block.Labels) != 2 || block.Labels[0] != route53RecordResource {
+			continue
+		}
+		if rec, ok := recordFromBlock(block, diag); ok {
+			previous = append(previous, previousResource{route53RecordResource + "." + block.Labels[1], rec})
+		}
+	}
+	return previous, nil
+}
+
+// planMoves matches the previous resources that no longer exist with the new
+// resources that did not exist before. Resources managing the same record set
+// are matched first, and then those with the same type and values, such as a
+// renamed record. When several resources match, none of them are moved and
+// the ambiguity is reported.
+func planMoves(previous []previousResource, current map[string]dnsRecord, diag *diagnostics) []resourceMove {
+	previousAddresses := make(map[string]bool)
+	var oldResources []previousResource
+	for _, p := range previous {
+		previousAddresses[p.address] = true
+		if _, ok := current[strings.TrimPrefix(p.address, route53RecordResource+".")]; !ok {
+			oldResources = append(oldResources, p)
+		}
+	}
+	var newResources []previousResource
+	for id, rec := range current {
+		address := route53RecordResource + "." + id
+		if !previousAddresses[address] {
+			newResources = append(newResources, previousResource{address, rec})
+		}
+	}
+	sort.Slice(newResources, func(i, j int) bool { return newResources[i].address < newResources[j].address })
+
+	matched := make(map[string]bool)
+	var moves []resourceMove
+	for _, matchKey := range []func(dnsRecord) string{
+		func(rec dnsRecord) string {
+			return rec.Name + " " + rec.Type
+		},
+		func(rec dnsRecord) string {
+			return rec.Type + " " + strings.Join(sortedData(rec), " ")
+		},
+	} {
+		olds := make(map[string][]string)
+		for _, p := range oldResources {
+			if !matched[p.address] {
+				key := matchKey(p.record)
+				olds[key] = append(olds[key], p.address)
+			}
+		}
+		news := make(map[string][]string)
+		var keys []string
+		for _, p := range newResources {
+			if !matched[p.address] {
+				key := matchKey(p.record)
+				if len(news[key]) == 0 {
+					keys = append(keys, key)
+				}
+				news[key] = append(news[key], p.address)
+			}
+		}
+
+		for _, key := range keys {
+			from, to := olds[key], news[key]
+			if len(from) == 0 {
+				continue
+			}
+			if len(from) == 1 && len(to) == 1 {
+				moves = append(moves, resourceMove{from[0], to[0]})
+			} else {
+				diag.warnf("Cannot tell which of %s became which of %s, not moving them", strings.Join(from, ", "), strings.Join(to, ", "))
+			}
+			for _, address := range append(from, to...) {
+				matched[address] = true
+			}
+		}
+	}
+
+	sort.Slice(moves, func(i, j int) bool { return moves[i].to < moves[j].to })
+	return moves
+}
+
+// writeMoves writes the moves from the previous resources to the generated
+// ones, as moved blocks, or as a terraform state mv script in legacy syntax.
+func (g *configGenerator) writeMoves(generated map[string]dnsRecord, output io.Writer) error {
+	moves := planMoves(g.previous, generated, g.diag)
+	if len(moves) == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	if g.syntax == Legacy {
+		b.WriteString("#!/bin/sh\nset -e\n")
+		for _, m := range moves {
+			fmt.Fprintf(&b, "terraform state mv '%s' '%s'\n", m.from, m.to)
+		}
+		_, err := io.WriteString(g.movedScript, b.String())
+		return err
+	}
+
+	for _, m := range moves {
+		fmt.Fprintf(&b, "\nmoved {\n  from = %s\n  to   = %s\n}\n", m.from, m.to)
+	}
+	_, err := io.WriteString(output, b.String())
+	return err
+}
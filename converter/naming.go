@@ -0,0 +1,190 @@
+package converter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+const defaultNamingStrategy = "fqdn"
+
+// resourceNamer names the resources of record sets after a naming strategy:
+//
+//	fqdn      the fully qualified record name and type, like www-example-com-A
+//	relative  the record name relative to the zone and type, like www-A
+//	hash      the type and a hash of the record name and type, like A-1f3870be
+//
+// or a text/template executed with a namingTemplateData, like
+// {{.Relative}}_{{.Type | lower}}. Names are made valid Terraform identifiers,
+// and unique within the zone by assign.
+type resourceNamer struct {
+	strategy string
+	template *template.Template
+	domain   string
+	names    map[recordKey]string
+}
+
+// namingTemplateData is passed to naming templates. Name is the fully
+// qualified record name without the trailing dot, while Relative is the name
+// relative to the zone, or apex for the zone apex. Hash is a hash of the
+// fully qualified name.
+type namingTemplateData struct {
+	Name     string
+	Relative string
+	Type     string
+	Hash     string
+}
+
+// newResourceNamer returns a namer for the records of the domain.
+func newResourceNamer(strategy, domain string) (*resourceNamer, error) {
+	n := &resourceNamer{strategy: strategy, domain: domain}
+	switch strategy {
+	case "fqdn", "relative", "hash":
+		return n, nil
+	}
+	if !strings.Contains(strategy, "{{") {
+		return nil, fmt.Errorf("Unknown naming strategy %q, must be fqdn, relative, hash or a template", strategy)
+	}
+	t, err := template.New("naming").Funcs(template.FuncMap{
+		"lower":    strings.ToLower,
+		"upper":    strings.ToUpper,
+		"sanitize": sanitizeRecordName,
+	}).Parse(strategy)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid naming template: %v", err)
+	}
+	n.template = t
+	return n, nil
+}
+
+// String describes the strategy, for the header of the output.
+func (n *resourceNamer) String() string {
+	if n.template != nil {
+		return fmt.Sprintf("template %q", n.strategy)
+	}
+	return n.strategy
+}
+
+// writeHeader records the naming strategy at the top of the output.
+func (n *resourceNamer) writeHeader(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# Generated by tfz53, resource naming strategy: %s\n\n", n)
+	return err
+}
+
+// baseName returns the name the strategy gives the resource of a record set,
+// before collisions are resolved.
+func (n *resourceNamer) baseName(key recordKey) (string, error) {
+	switch n.strategy {
+	case "fqdn":
+		return recordSetResourceID(dnsRecord{Name: key.Name, Type: key.Type}), nil
+	case "relative":
+		return fmt.Sprintf("%s-%s", sanitizeRecordName(n.relative(key.Name)), key.Type), nil
+	case "hash":
+		return fmt.Sprintf("%s-%s", key.Type, valueHash(key.Name+" "+key.Type)), nil
+	}
+
+	var b strings.Builder
+	err := n.template.Execute(&b, namingTemplateData{
+		Name:     strings.TrimRight(key.Name, "."),
+		Relative: n.relative(key.Name),
+		Type:     key.Type,
+		Hash:     valueHash(key.Name),
+	})
+	if err != nil {
+		return "", err
+	}
+	if b.Len() == 0 {
+		return "", fmt.Errorf("Naming template gives an empty name for %s %s", key.Name, key.Type)
+	}
+	return sanitizeRecordName(b.String()), nil
+}
+
+func (n *resourceNamer) relative(name string) string {
+	rel := relativeName(name, n.domain)
+	if rel == "@" {
+		return "apex"
+	}
+	return rel
+}
+
+// collisionSuffix is appended to the names of record sets that the strategy
+// gives the same name.
+func collisionSuffix(key recordKey) string {
+	return "-" + valueHash(key.Name+" "+key.Type)
+}
+
+// assign names the record sets of the zone. Record sets that the strategy gives
+// the same name, such as a.b.example.com and a-b.example.com, all get a hash
+// of their name and type appended, so that their names do not depend on which
+// other record sets exist.
+func (n *resourceNamer) assign(keys []recordKey, diag *diagnostics) error {
+	n.names = make(map[recordKey]string, len(keys))
+
+	byName := make(map[string][]recordKey)
+	for _, key := range keys {
+		name, err := n.baseName(key)
+		if err != nil {
+			return err
+		}
+		byName[name] = append(byName[name], key)
+	}
+
+	var collisions []string
+	for name, named := range byName {
+		if len(named) == 1 {
+			n.names[named[0]] = name
+			continue
+		}
+		collisions = append(collisions, name)
+		for _, key := range named {
+			n.names[key] = name + collisionSuffix(key)
+		}
+	}
+
+	sort.Strings(collisions)
+	for _, name := range collisions {
+		named := byName[name]
+		sort.Sort(recordKeySlice(named))
+		sets := make([]string, len(named))
+		for i, key := range named {
+			sets[i] = fmt.Sprintf("%s %s as %s", key.Name, key.Type, n.names[key])
+		}
+		diag.warnf("Several record sets would be named %s, naming %s", name, strings.Join(sets, ", "))
+	}
+
+	seen := make(map[string]recordKey, len(n.names))
+	for _, key := range keys {
+		name := n.names[key]
+		if other, ok := seen[name]; ok {
+			return fmt.Errorf("Cannot give %s %s and %s %s different resource names", other.Name, other.Type, key.Name, key.Type)
+		}
+		seen[name] = key
+	}
+	return nil
+}
+
+// name returns the resource name of the record set. Record sets that were not
+// assigned a name get the one the strategy gives them, or the fqdn one when
+// the template fails for them.
+func (n *resourceNamer) name(key recordKey) string {
+	if name, ok := n.names[key]; ok {
+		return name
+	}
+	name, err := n.baseName(key)
+	if err != nil {
+		return recordSetResourceID(dnsRecord{Name: key.Name, Type: key.Type})
+	}
+	return name
+}
+
+// generatedName reports whether a resource name is one the strategy gives the
+// record set, with or without the suffix resolving collisions.
+func (n *resourceNamer) generatedName(key recordKey, name string) bool {
+	base, err := n.baseName(key)
+	if err != nil {
+		return false
+	}
+	return name == base || name == base+collisionSuffix(key)
+}
@@ -0,0 +1,121 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// providerTarget describes how a DNS provider represents a zone and its
+// records in Terraform. The generator parses and merges the zone records, and
+// then asks the target how to group, name and encode them.
+//
+// A new target implements this interface in its own file and registers itself
+// with registerProvider from an init function. It also needs golden files
+// named <zone>.expected-<provider>-<syntax> in testdata for TestAcceptance.
+type providerTarget interface {
+	// String returns the name of the provider, as passed to -provider.
+	String() string
+	// templates returns the templates rendering the zone resource and a
+	// single record resource.
+	templates() (zone string, record string)
+	// prepareZone fills in the provider specific parts of the zone resource.
+	prepareZone(zone *zoneTemplateData)
+	// zoneReference returns the expression referring to the zone in record
+	// resources. The generator adapts it to the configured syntax.
+	zoneReference(zoneID string) string
+	// supportsType reports whether the provider can represent records of the
+	// given type.
+	supportsType(rrType string) bool
+	// groupRecords splits a record set into the groups managed by a single
+	// resource each.
+	groupRecords(record dnsRecord, diag *diagnostics) []dnsRecord
+	// recordName returns the record name in the form the provider expects.
+	recordName(name, domain string) string
+	// resourceID returns the Terraform resource name of a record group, given
+	// the name the naming strategy gives its record set.
+	resourceID(setID string, record dnsRecord) string
+	// encodeRecord populates the values of the record resource. An error
+	// means the record cannot be represented by the provider.
+	encodeRecord(data *recordTemplateData, diag *diagnostics) error
+}
+
+var providerTargets = make(map[string]func() providerTarget)
+
+// registerProvider makes a provider target selectable by its name.
+func registerProvider(newTarget func() providerTarget) {
+	name := newTarget().String()
+	if _, ok := providerTargets[name]; ok {
+		panic(fmt.Sprintf("Provider %s registered twice", name))
+	}
+	providerTargets[name] = newTarget
+}
+
+func providerNames() []string {
+	names := make([]string, 0, len(providerTargets))
+	for name := range providerTargets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func providerFromString(s string) (providerTarget, error) {
+	newTarget, ok := providerTargets[strings.ToLower(s)]
+	if !ok {
+		return nil, fmt.Errorf("Unknown provider %q, must be one of %s", s, strings.Join(providerNames(), ", "))
+	}
+	return newTarget(), nil
+}
+
+// recordSetResourceID names a resource managing a whole record set after its
+// name and type. This is the fqdn naming strategy.
+func recordSetResourceID(record dnsRecord) string {
+	return fmt.Sprintf("%s-%s", sanitizeRecordName(record.Name), record.Type)
+}
+
+// valueResourceID names a resource managing a single value after its record
+// set and a hash of the value, so that it stays stable when other values are
+// added to or removed from the set.
+func valueResourceID(setID string, record dnsRecord) string {
+	return fmt.Sprintf("%s-%s", setID, valueHash(record.Data[0]))
+}
+
+// groupByRecordSet keeps the record set as a single group.
+func groupByRecordSet(record dnsRecord, diag *diagnostics) []dnsRecord {
+	return []dnsRecord{record}
+}
+
+// groupByValue splits a record set into one group per value, for providers
+// that do not group values into sets. Comments are kept with the first group
+// only, while annotations apply to all. Duplicate values are dropped.
+func groupByValue(record dnsRecord, diag *diagnostics) []dnsRecord {
+	groups := make([]dnsRecord, 0, len(record.Data))
+	seen := make(map[string]bool)
+	for _, value := range record.Data {
+		if seen[value] {
+			diag.recordWarnf(record, "Skipping duplicate %s value %s for %s", record.Type, value, record.Name)
+			continue
+		}
+		seen[value] = true
+
+		group := record
+		group.Data = []string{value}
+		if len(groups) > 0 {
+			group.Comments = nil
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// errorList collects the errors of several record groups.
+type errorList []error
+
+func (errs errorList) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,101 @@
+package converter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// pulumiWriter writes a zone as a Pulumi YAML program with an aws:route53:Zone
+// and an aws:route53:Record per record set. With existingZone set, the hosted
+// zone is looked up by name instead of created. The record resources are named
+// after the naming strategy.
+type pulumiWriter struct {
+	existingZone bool
+	naming       string
+}
+
+func (p *pulumiWriter) writeZone(domain string, records map[recordKey]dnsRecord, w io.Writer, diag *diagnostics) error {
+	zoneName := strings.TrimRight(domain, ".")
+	zoneID := strings.Replace(zoneName, ".", "-", -1)
+
+	namer, err := newResourceNamer(p.naming, domain)
+	if err != nil {
+		return err
+	}
+	if err := namer.assign(sortedRecordKeys(records), diag); err != nil {
+		return err
+	}
+
+	program := yamlMap{
+		{"name", zoneID},
+		{"runtime", "yaml"},
+		{"description", fmt.Sprintf("Route 53 hosted zone for %s, generated by tfz53", zoneName)},
+	}
+
+	resources := yamlMap{}
+	if p.existingZone {
+		program = append(program, yamlField{"variables", yamlMap{
+			{zoneID, yamlMap{
+				{"fn::invoke", yamlMap{
+					{"function", "aws:route53:getZone"},
+					{"arguments", yamlMap{
+						{"name", zoneName},
+					}},
+				}},
+			}},
+		}})
+	} else {
+		resources = append(resources, yamlField{zoneID, yamlMap{
+			{"type", "aws:route53:Zone"},
+			{"properties", yamlMap{
+				{"name", zoneName},
+			}},
+		}})
+	}
+
+	for _, key := range sortedRecordKeys(records) {
+		rec := records[key]
+		values := make([]interface{}, len(rec.Data))
+		for i, d := range rec.Data {
+			values[i] = pulumiString(terraformValue(rec.Type, d))
+		}
+		resources = append(resources, yamlField{namer.name(key), yamlMap{
+			{"type", "aws:route53:Record"},
+			{"properties", yamlMap{
+				{"zoneId", fmt.Sprintf("${%s.zoneId}", zoneID)},
+				{"name", rec.Name},
+				{"type", rec.Type},
+				{"ttl", rec.TTL},
+				{"records", values},
+			}},
+		}})
+	}
+
+	program = append(program,
+		yamlField{"resources", resources},
+		yamlField{"outputs", yamlMap{
+			{"zoneId", fmt.Sprintf("${%s.zoneId}", zoneID)},
+			{"nameServers", fmt.Sprintf("${%s.nameServers}", zoneID)},
+		}},
+	)
+	if err := namer.writeHeader(w); err != nil {
+		return err
+	}
+	return writeYAML(w, program)
+}
+
+// terraformValue returns the value of record data as the Terraform AWS
+// provider receives it, which Pulumi's AWS provider shares.
+func terraformValue(rrType, data string) string {
+	if isTXTType(rrType) {
+		return terraformTXT(data)
+	}
+	return data
+}
+
+// pulumiString escapes the interpolation sequences of a string, which Pulumi
+// YAML takes literally when written as $${.
+func pulumiString(s string) string {
+	return strings.Replace(s, "${", "$${", -1)
+}
@@ -0,0 +1,28 @@
+package converter
+
+import (
+	"fmt"
+
+	"github.com/miekg/dns"
+)
+
+// parseRecordData parses the presentation format data of a record, as kept in
+// dnsRecord.Data, into the corresponding dns.RR. This gives structured access
+// to the fields of types such as MX, SRV and CAA for providers that do not
+// take the data as an opaque string.
+func parseRecordData(rrType, data string) (dns.RR, error) {
+	rr, err := dns.NewRR(fmt.Sprintf(". 0 IN %s %s", rrType, data))
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse %s data %q: %v", rrType, data, err)
+	}
+	if rr == nil {
+		return nil, fmt.Errorf("Cannot parse empty %s data", rrType)
+	}
+	return rr, nil
+}
+
+// txtValue joins the character-strings of TXT data into a single quoted
+// string, for providers that split long values by themselves.
+func txtValue(data string) string {
+	return terraformString(joinedTXT(data))
+}
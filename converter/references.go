@@ -0,0 +1,156 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// resolveStatus is the outcome of resolving a target offline.
+type resolveStatus int
+
+const (
+	// resolveExternal is a target outside the zone and its siblings, which
+	// cannot be checked offline.
+	resolveExternal resolveStatus = iota
+	resolveFound
+	resolveDangling
+	resolveLoop
+)
+
+// resolution is a target resolved against the zones of a batch. Chain holds
+// the names followed, starting with the target and following CNAME records,
+// and Sets the record sets of the last of them.
+type resolution struct {
+	status resolveStatus
+	chain  []string
+	zone   *lintZone
+	sets   []dnsRecord
+}
+
+// name returns the last name of the chain.
+func (r resolution) name() string {
+	return r.chain[len(r.chain)-1]
+}
+
+// path formats the chain of names, such as a.example.com. -> b.example.com.
+func (r resolution) path() string {
+	return strings.Join(r.chain, " -> ")
+}
+
+// hasAddress returns whether the last name has A or AAAA records.
+func (r resolution) hasAddress() bool {
+	for _, set := range r.sets {
+		if set.Type == "A" || set.Type == "AAAA" {
+			return true
+		}
+	}
+	return false
+}
+
+// zoneOf returns the zone of the batch a name belongs to, the one with the
+// longest apex when zones are nested, or nil when it is outside all of them.
+func (z *lintZone) zoneOf(name string) *lintZone {
+	var zone *lintZone
+	for _, candidate := range append([]*lintZone{z}, z.siblings...) {
+		if candidate.inZone(name) && (zone == nil || len(candidate.apex) > len(zone.apex)) {
+			zone = candidate
+		}
+	}
+	return zone
+}
+
+// lookup returns the record sets of a name, or of the wildcard that applies
+// to it, and whether the name exists. Empty non-terminals exist without
+// record sets.
+func (z *lintZone) lookup(name string) ([]dnsRecord, bool) {
+	if sets, ok := z.names[name]; ok {
+		return sets, true
+	}
+	if _, ok := z.nonTerminals[name]; ok {
+		return nil, true
+	}
+	// A wildcard applies to the names below its parent, the closest
+	// encloser, that do not exist
+	encloser := parentName(name)
+	for encloser != "" && z.inZone(encloser) && !z.exists(encloser) {
+		encloser = parentName(encloser)
+	}
+	if encloser == "" || !z.inZone(encloser) {
+		return nil, false
+	}
+	sets, ok := z.names["*."+strings.TrimPrefix(encloser, ".")]
+	return sets, ok
+}
+
+// resolve follows a target through CNAME records in the zone and its
+// siblings, until it reaches a name without a CNAME record, a name that does
+// not exist, a name outside the batch, or a name already followed.
+func (z *lintZone) resolve(target string) resolution {
+	visited := make(map[string]bool)
+	res := resolution{}
+	for name := target; ; {
+		res.chain = append(res.chain, name)
+		if visited[name] {
+			res.status = resolveLoop
+			return res
+		}
+		visited[name] = true
+
+		res.zone = z.zoneOf(name)
+		if res.zone == nil {
+			res.status = resolveExternal
+			return res
+		}
+		sets, ok := res.zone.lookup(name)
+		if !ok {
+			res.status = resolveDangling
+			return res
+		}
+		res.sets = sets
+		next := ""
+		for _, set := range sets {
+			if set.Type == "CNAME" && len(set.Data) > 0 {
+				next = strings.ToLower(strings.Fields(set.Data[0])[0])
+			}
+		}
+		if next == "" {
+			res.status = resolveFound
+			return res
+		}
+		name = next
+	}
+}
+
+// lintUnresolvableTarget reports targets in the zone or its siblings that do
+// not exist, following CNAME records, and MX, NS and SRV targets that end at
+// a name without addresses.
+func lintUnresolvableTarget(z *lintZone, report lintReporter) {
+	for _, key := range z.keys {
+		rec := z.records[key]
+		for _, target := range recordTargets(rec) {
+			res := z.resolve(target)
+			switch {
+			case res.status == resolveDangling:
+				report(rec, "remove the record, or add the target",
+					"%s %s points at %s, which does not exist in the zone %s", rec.Name, rec.Type, res.path(), res.zone.apex)
+			case res.status == resolveFound && rec.Type != "CNAME" && !res.hasAddress():
+				report(rec, fmt.Sprintf("add A or AAAA records to %s", res.name()),
+					"%s %s points at %s, which has no A or AAAA records", rec.Name, rec.Type, res.path())
+			}
+		}
+	}
+}
+
+// lintCNAMELoop reports targets whose CNAME records lead back to a name
+// already followed, which resolvers give up on.
+func lintCNAMELoop(z *lintZone, report lintReporter) {
+	for _, key := range z.keys {
+		rec := z.records[key]
+		for _, target := range recordTargets(rec) {
+			if res := z.resolve(target); res.status == resolveLoop {
+				report(rec, "point one of the CNAME records at a name with records",
+					"%s %s points at %s, which is a CNAME loop", rec.Name, rec.Type, res.path())
+			}
+		}
+	}
+}
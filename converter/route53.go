@@ -0,0 +1,208 @@
+package converter
+
+import (
+	"fmt"
+)
+
+const (
+	zoneTemplateStr = `resource "aws_route53_zone" "{{ .ID }}" {
+  name = "{{ .Domain }}"
+}
+`
+	existingZoneTemplateStr = `data "aws_route53_zone" "{{ .ID }}" {
+  name = "{{ .Domain }}"
+}
+`
+	recordTemplateStr = `{{- range .Record.Comments }}
+# {{ . }}{{ end }}
+resource "aws_route53_record" "{{ .ResourceID }}" {
+  zone_id = {{ .ZoneReference }}
+  name    = "{{ .Name }}"
+  type    = "{{ .Record.Type }}"
+  ttl     = "{{ .Record.TTL }}"
+  records = [{{ range $idx, $elem := .Values }}{{ if $idx }}, {{ end }}{{ $elem }}{{ end }}]
+}
+`
+	nameServerTemplateStr = `
+resource "aws_route53_record" "{{ .ResourceID }}" {
+  allow_overwrite = true
+  zone_id         = {{ .ZoneReference }}
+  name            = "{{ .Name }}"
+  type            = "NS"
+  ttl             = "{{ .TTL }}"
+  records         = {{ .NameServers }}
+}
+`
+)
+
+const (
+	// route53MaxRecordsPerChange is the number of ResourceRecord elements a
+	// single change request may contain.
+	route53MaxRecordsPerChange = 1000
+	// route53MaxValueCharsPerChange is the number of characters all values
+	// of a single change request may contain together.
+	route53MaxValueCharsPerChange = 32000
+	// route53UpsertWeight is how many times each record of an UPSERT counts
+	// towards the limits of a change request.
+	route53UpsertWeight = 2
+)
+
+func init() {
+	registerProvider(func() providerTarget { return &route53Target{} })
+}
+
+// route53Target generates aws_route53_zone and aws_route53_record resources.
+// With existingZone set, the records are added to a hosted zone looked up by
+// name instead.
+type route53Target struct {
+	existingZone bool
+}
+
+func (t *route53Target) String() string {
+	return "route53"
+}
+
+func (t *route53Target) templates() (string, string) {
+	if t.existingZone {
+		return existingZoneTemplateStr, recordTemplateStr
+	}
+	return zoneTemplateStr, recordTemplateStr
+}
+
+func (t *route53Target) prepareZone(zone *zoneTemplateData) {}
+
+func (t *route53Target) zoneReference(zoneID string) string {
+	return fmt.Sprintf("%s.zone_id", t.zoneAddress(zoneID))
+}
+
+func (t *route53Target) zoneAddress(zoneID string) string {
+	if t.existingZone {
+		return fmt.Sprintf("data.aws_route53_zone.%s", zoneID)
+	}
+	return fmt.Sprintf("aws_route53_zone.%s", zoneID)
+}
+
+func (t *route53Target) supportsType(rrType string) bool {
+	return route53SupportedTypes[rrType]
+}
+
+func (t *route53Target) groupRecords(record dnsRecord, diag *diagnostics) []dnsRecord {
+	return groupByRecordSet(record, diag)
+}
+
+func (t *route53Target) recordName(name, domain string) string {
+	return name
+}
+
+func (t *route53Target) resourceID(setID string, record dnsRecord) string {
+	return setID
+}
+
+func (t *route53Target) encodeRecord(data *recordTemplateData, diag *diagnostics) error {
+	for _, d := range data.Record.Data {
+		if isTXTType(data.Record.Type) {
+			data.Values = append(data.Values, terraformString(terraformTXT(d)))
+			continue
+		}
+		data.Values = append(data.Values, ensureQuoted(d))
+	}
+	return nil
+}
+
+// nameServers qualifies the name servers, which Route 53 returns without a
+// trailing dot.
+func (t *route53Target) nameServers(zoneID string) string {
+	return fmt.Sprintf(`formatlist("%%s.", %s.name_servers)`, t.zoneAddress(zoneID))
+}
+
+func (t *route53Target) nameServerTemplate() string {
+	return nameServerTemplateStr
+}
+
+func (t *route53Target) handlingNotes(record dnsRecord) []string {
+	return nil
+}
+
+// route53Value converts record data to a value for the Route 53 API, which
+// expects octal escapes in TXT and SPF character-strings.
+func route53Value(rrType, data string) string {
+	if isTXTType(rrType) {
+		return route53TXT(data)
+	}
+	return data
+}
+
+// zoneValue converts a value of the Route 53 API back to record data, in the
+// canonical form of records read from zone files.
+func zoneValue(rrType, value string) string {
+	if isTXTType(rrType) {
+		value = formatTXT(parseTXT(value, true), false)
+	}
+	return canonicalValue(rrType, value)
+}
+
+// route53ChangeSize is how much one or more changes count towards the limits
+// of a Route 53 change request.
+type route53ChangeSize struct {
+	records, chars int
+}
+
+// recordSetChangeSize returns the size of a change to the record set, where
+// each record counts weight times.
+func recordSetChangeSize(rec dnsRecord, weight int) route53ChangeSize {
+	size := route53ChangeSize{records: len(rec.Data) * weight}
+	for _, d := range rec.Data {
+		size.chars += len(route53Value(rec.Type, d)) * weight
+	}
+	return size
+}
+
+func (s route53ChangeSize) add(o route53ChangeSize) route53ChangeSize {
+	return route53ChangeSize{s.records + o.records, s.chars + o.chars}
+}
+
+func (s route53ChangeSize) exceedsLimits() bool {
+	return s.records > route53MaxRecordsPerChange || s.chars > route53MaxValueCharsPerChange
+}
+
+// chunkBySize splits consecutive changes of the given sizes into chunks that
+// fit in a single Route 53 change request, returning the number of changes in
+// each chunk. A change that exceeds the limits by itself gets a chunk of its
+// own.
+func chunkBySize(sizes []route53ChangeSize) []int {
+	var chunks []int
+	var n int
+	var total route53ChangeSize
+	for _, size := range sizes {
+		if n > 0 && total.add(size).exceedsLimits() {
+			chunks = append(chunks, n)
+			n, total = 0, route53ChangeSize{}
+		}
+		n++
+		total = total.add(size)
+	}
+	if n > 0 {
+		chunks = append(chunks, n)
+	}
+	return chunks
+}
+
+// chunkRecordSets splits record sets into chunks that fit in a single Route 53
+// change request, where each record counts weight times towards the limits.
+// A record set that exceeds the limits by itself gets a chunk of its own.
+func chunkRecordSets(records []dnsRecord, weight int, diag *diagnostics) [][]dnsRecord {
+	sizes := make([]route53ChangeSize, len(records))
+	for i, rec := range records {
+		sizes[i] = recordSetChangeSize(rec, weight)
+		if sizes[i].exceedsLimits() {
+			diag.recordWarnf(rec, "%s %s exceeds the size limits of a Route 53 change request by itself", rec.Name, rec.Type)
+		}
+	}
+
+	var chunks [][]dnsRecord
+	for _, n := range chunkBySize(sizes) {
+		chunks = append(chunks, records[:n])
+		records = records[n:]
+	}
+	return chunks
+}
@@ -0,0 +1,129 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// route53SigningRegion is the region requests to the global Route 53
+// endpoint are signed for, unless another region is configured.
+const route53SigningRegion = "us-east-1"
+
+// route53Client is a client of the Route 53 API, covering what is needed to
+// apply a zone.
+type route53Client struct {
+	api *route53.Client
+}
+
+// newRoute53Client returns a client with the credentials and settings found
+// like the AWS CLI finds them: in the environment, in the shared config and
+// credentials files, including profiles and SSO, or from the instance role.
+// The endpoint, if given, overrides the endpoint of Route 53, such as to
+// point at a stand-in for tests.
+func newRoute53Client(endpoint string, optFns ...func(*config.LoadOptions) error) (*route53Client, error) {
+	cfg, err := config.LoadDefaultConfig(context.Background(), optFns...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load the AWS configuration: %v", err)
+	}
+	api := route53.NewFromConfig(cfg, func(o *route53.Options) {
+		if o.Region == "" {
+			o.Region = route53SigningRegion
+		}
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+	return &route53Client{api}, nil
+}
+
+// findHostedZone returns the ID of the public or private hosted zone with the
+// given name, which must be unambiguous.
+func (c *route53Client) findHostedZone(name string) (string, error) {
+	resp, err := c.api.ListHostedZonesByName(context.Background(), &route53.ListHostedZonesByNameInput{
+		DNSName:  aws.String(name),
+		MaxItems: aws.Int32(2),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var ids []string
+	for _, zone := range resp.HostedZones {
+		// Zones are listed from the given name on, so the first ones need not
+		// match
+		if strings.EqualFold(aws.ToString(zone.Name), name) {
+			ids = append(ids, strings.TrimPrefix(aws.ToString(zone.Id), "/hostedzone/"))
+		}
+	}
+	switch len(ids) {
+	case 0:
+		return "", fmt.Errorf("No hosted zone named %s", name)
+	case 1:
+		return ids[0], nil
+	default:
+		return "", fmt.Errorf("Several hosted zones are named %s, select one by its ID", name)
+	}
+}
+
+// listRecordSets returns all record sets of the hosted zone.
+func (c *route53Client) listRecordSets(zoneID string) ([]types.ResourceRecordSet, error) {
+	var sets []types.ResourceRecordSet
+	pages := route53.NewListResourceRecordSetsPaginator(c.api, &route53.ListResourceRecordSetsInput{
+		HostedZoneId: aws.String(zoneID),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, page.ResourceRecordSets...)
+	}
+	return sets, nil
+}
+
+// changeRecordSets submits a change batch, returning the ID of the change.
+func (c *route53Client) changeRecordSets(zoneID, comment string, changes []types.Change) (string, error) {
+	resp, err := c.api.ChangeResourceRecordSets(context.Background(), &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(zoneID),
+		ChangeBatch: &types.ChangeBatch{
+			Comment: aws.String(comment),
+			Changes: changes,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(aws.ToString(resp.ChangeInfo.Id), "/change/"), nil
+}
+
+// changeStatus returns the status of a change, PENDING until it has been
+// propagated to all Route 53 name servers and INSYNC after.
+func (c *route53Client) changeStatus(changeID string) (types.ChangeStatus, error) {
+	resp, err := c.api.GetChange(context.Background(), &route53.GetChangeInput{
+		Id: aws.String(changeID),
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.ChangeInfo.Status, nil
+}
+
+var route53NameEscape = regexp.MustCompile(`\\[0-7]{3}`)
+
+// unescapeRoute53Name decodes the octal escapes Route 53 uses for characters
+// other than letters, digits, hyphens and dots in names, such as \052 for the
+// asterisk of wildcard records.
+func unescapeRoute53Name(name string) string {
+	return route53NameEscape.ReplaceAllStringFunc(name, func(esc string) string {
+		c, _ := strconv.ParseUint(esc[1:], 8, 8)
+		return string([]byte{byte(c)})
+	})
+}
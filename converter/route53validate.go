@@ -0,0 +1,159 @@
+package converter
+
+import (
+	"fmt"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// route53MaxValuesPerSet is the number of values a record set can hold.
+	route53MaxValuesPerSet = 400
+	// route53MaxValueChars is the length of a single value, as sent to the
+	// Route 53 API.
+	route53MaxValueChars = 4000
+	// route53DefaultRecordSetQuota is the default quota of record sets in a
+	// hosted zone, which AWS can raise on request.
+	route53DefaultRecordSetQuota = 10000
+
+	dnsMaxNameOctets  = 255
+	dnsMaxLabelOctets = 63
+)
+
+// route53SupportedTypes lists the record types Route 53 accepts in a hosted
+// zone.
+var route53SupportedTypes = map[string]bool{
+	"A":     true,
+	"AAAA":  true,
+	"CAA":   true,
+	"CNAME": true,
+	"DS":    true,
+	"HTTPS": true,
+	"MX":    true,
+	"NAPTR": true,
+	"NS":    true,
+	"PTR":   true,
+	"SOA":   true,
+	"SPF":   true,
+	"SRV":   true,
+	"SSHFP": true,
+	"SVCB":  true,
+	"TLSA":  true,
+	"TXT":   true,
+}
+
+// validateRoute53 checks the record sets against what Route 53 accepts,
+// leaving out those it would reject, so that the rest of the zone can still
+// be created. SPF record sets are converted to TXT, as Route 53 no longer
+// recommends the SPF type, unless they conflict with a TXT record set. Each
+// finding suggests a fix for the zone file.
+func validateRoute53(records map[recordKey]dnsRecord, diag *diagnostics) {
+	for _, key := range sortedRecordKeys(records) {
+		if key.Type == "SPF" {
+			convertSPFToTXT(records, key, diag)
+		}
+	}
+
+	for _, key := range sortedRecordKeys(records) {
+		rec := records[key]
+		if !validRoute53RecordSet(rec, diag) {
+			delete(records, key)
+		}
+	}
+
+	if len(records) > route53DefaultRecordSetQuota {
+		diag.fixf(Warning, "", 0, "request a quota increase for records per hosted zone, or split the zone",
+			"The zone has %d record sets, more than the default Route 53 quota of %d per hosted zone", len(records), route53DefaultRecordSetQuota)
+	}
+}
+
+// validRoute53RecordSet reports the problems of a record set that Route 53
+// would reject, returning whether it has none.
+func validRoute53RecordSet(rec dnsRecord, diag *diagnostics) bool {
+	if !route53SupportedTypes[rec.Type] {
+		diag.recordFixf(Error, rec, unsupportedTypeFix(rec.Type), "Route 53 does not support %s records, skipping %s", rec.Type, rec.Name)
+		return false
+	}
+
+	valid := true
+	if msg, ok := checkNameLength(rec.Name); !ok {
+		diag.recordFixf(Error, rec, "shorten the name", "%s %s %s, skipping the record set", rec.Name, rec.Type, msg)
+		valid = false
+	}
+	if len(rec.Data) > route53MaxValuesPerSet {
+		diag.recordFixf(Error, rec, "spread the values over several names", "%s %s has %d values, more than the %d Route 53 allows in a record set, skipping the record set", rec.Name, rec.Type, len(rec.Data), route53MaxValuesPerSet)
+		valid = false
+	}
+	for _, d := range rec.Data {
+		if n := len(route53Value(rec.Type, d)); n > route53MaxValueChars {
+			diag.recordFixf(Error, rec, "shorten the value", "A value of %s %s has %d characters, more than the %d Route 53 allows, skipping the record set", rec.Name, rec.Type, n, route53MaxValueChars)
+			valid = false
+			break
+		}
+	}
+	return valid
+}
+
+// checkNameLength checks the length of a domain name, and of each of its
+// labels, in the wire format, where escapes count as a single octet.
+func checkNameLength(name string) (string, bool) {
+	octets := 1
+	for _, label := range dns.SplitDomainName(name) {
+		n := len(unescapeTXT(label, false))
+		if n > dnsMaxLabelOctets {
+			return fmt.Sprintf("has a label of %d octets, more than the %d allowed", n, dnsMaxLabelOctets), false
+		}
+		octets += n + 1
+	}
+	if octets > dnsMaxNameOctets {
+		return fmt.Sprintf("is %d octets long, more than the %d allowed", octets, dnsMaxNameOctets), false
+	}
+	return "", true
+}
+
+// convertSPFToTXT converts an SPF record set to TXT, unless there is a TXT
+// record set of the same name. One with the same values makes the SPF record
+// set redundant, while one with other values conflicts with it, as merging
+// them could publish several SPF policies, which receivers reject. The SPF
+// record set is then kept as it is.
+func convertSPFToTXT(records map[recordKey]dnsRecord, key recordKey, diag *diagnostics) {
+	spf := records[key]
+	txtKey := recordKey{key.Name, "TXT"}
+	txt, ok := records[txtKey]
+	switch {
+	case !ok:
+		delete(records, key)
+		spf.Type = "TXT"
+		records[txtKey] = spf
+		diag.recordFixf(Warning, spf, "replace the SPF record with a TXT record", "Converting SPF %s to TXT, as the SPF type is deprecated", key.Name)
+	case containsAll(txt.Data, spf.Data):
+		delete(records, key)
+		diag.recordFixf(Warning, spf, "remove the SPF record", "Skipping SPF %s, as its TXT record has the same values", key.Name)
+	default:
+		diag.recordFixf(Warning, spf, "merge the SPF values into the TXT record, keeping a single v=spf1 policy, and remove the SPF record", "Keeping SPF %s, as its TXT record has other values", key.Name)
+	}
+}
+
+func containsAll(values, subset []string) bool {
+	for _, v := range subset {
+		if !containsString(values, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// unsupportedTypeFix suggests how to replace a record type Route 53 does not
+// support.
+func unsupportedTypeFix(rrType string) string {
+	switch rrType {
+	case "DNAME":
+		return "replace it with CNAME records for the names in use below it"
+	case "LOC", "HINFO", "RP":
+		return "remove it, or publish the information in a TXT record"
+	case "DNSKEY", "RRSIG", "NSEC", "NSEC3", "NSEC3PARAM", "CDS", "CDNSKEY":
+		return "remove it, as Route 53 signs the zone itself when DNSSEC signing is enabled"
+	default:
+		return "remove it, or host the zone with a provider that supports " + rrType + " records"
+	}
+}
@@ -0,0 +1,79 @@
+package converter
+
+import "io"
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// writeSARIF writes the findings as a SARIF log, which code scanning tools
+// show next to the zone file. The enabled rules are described by the tool,
+// with their configured severity.
+func writeSARIF(w io.Writer, findings []Diagnostic, severities map[string]Severity) error {
+	rules := make([]interface{}, 0, len(severities))
+	for _, rule := range lintRules {
+		severity, ok := severities[rule.id]
+		if !ok {
+			continue
+		}
+		descriptor := yamlMap{
+			{"id", rule.id},
+			{"shortDescription", yamlMap{{"text", rule.description}}},
+			{"defaultConfiguration", yamlMap{{"level", sarifLevel(severity)}}},
+		}
+		if rule.securitySeverity != "" {
+			descriptor = append(descriptor, yamlField{"properties", yamlMap{
+				{"security-severity", rule.securitySeverity},
+				{"tags", []interface{}{"security"}},
+			}})
+		}
+		rules = append(rules, descriptor)
+	}
+
+	results := make([]interface{}, len(findings))
+	for i, f := range findings {
+		message := f.Message
+		if f.Fix != "" {
+			message += ". Fix: " + f.Fix
+		}
+		result := yamlMap{
+			{"ruleId", f.Rule},
+			{"level", sarifLevel(f.Severity)},
+			{"message", yamlMap{{"text", message}}},
+		}
+		if f.File != "" {
+			location := yamlMap{{"artifactLocation", yamlMap{{"uri", f.File}}}}
+			if f.Line > 0 {
+				location = append(location, yamlField{"region", yamlMap{{"startLine", f.Line}}})
+			}
+			result = append(result, yamlField{"locations", []interface{}{
+				yamlMap{{"physicalLocation", location}},
+			}})
+		}
+		results[i] = result
+	}
+
+	return writeJSON(w, yamlMap{
+		{"$schema", sarifSchema},
+		{"version", sarifVersion},
+		{"runs", []interface{}{
+			yamlMap{
+				{"tool", yamlMap{{"driver", yamlMap{
+					{"name", "tfz53"},
+					{"informationUri", "https://github.com/carlpett/tfz53"},
+					{"rules", rules},
+				}}}},
+				{"results", results},
+			},
+		}},
+	})
+}
+
+// sarifLevel returns the SARIF level of a severity, where info is a note.
+func sarifLevel(severity Severity) string {
+	if severity == Info {
+		return "note"
+	}
+	return severity.String()
+}
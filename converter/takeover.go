@@ -0,0 +1,155 @@
+package converter
+
+import (
+	"bytes"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// takeoverSuffixes are the suffixes of endpoints of services that let anyone
+// claim a name once its owner deprovisions it, by the service they belong
+// to. A label of a suffix may be a pattern, as in path.Match.
+var takeoverSuffixes = map[string]string{
+	"s3.amazonaws.com":           "AWS S3",
+	"s3.*.amazonaws.com":         "AWS S3",
+	"s3-website-*.amazonaws.com": "AWS S3",
+	"s3-website.*.amazonaws.com": "AWS S3",
+	"elasticbeanstalk.com":       "AWS Elastic Beanstalk",
+	"azure-api.net":              "Azure API Management",
+	"azurecontainer.io":          "Azure Container Instances",
+	"azureedge.net":              "Azure CDN",
+	"azurefd.net":                "Azure Front Door",
+	"azurewebsites.net":          "Azure App Service",
+	"blob.core.windows.net":      "Azure Blob Storage",
+	"cloudapp.azure.com":         "Azure public IP",
+	"cloudapp.net":               "Azure Cloud Services",
+	"trafficmanager.net":         "Azure Traffic Manager",
+	"bitbucket.io":               "Bitbucket",
+	"ghost.io":                   "Ghost",
+	"github.io":                  "GitHub Pages",
+	"helpjuice.com":              "Helpjuice",
+	"helpscoutdocs.com":          "Help Scout",
+	"herokuapp.com":              "Heroku",
+	"herokudns.com":              "Heroku",
+	"myshopify.com":              "Shopify",
+	"netlify.app":                "Netlify",
+	"pantheonsite.io":            "Pantheon",
+	"readme.io":                  "ReadMe",
+	"surge.sh":                   "Surge",
+	"domains.tumblr.com":         "Tumblr",
+	"unbouncepages.com":          "Unbounce",
+}
+
+// takeoverSuffix is a suffix of takeover-prone endpoints, split into labels.
+type takeoverSuffix struct {
+	labels  []string
+	service string
+}
+
+// takeoverSuffixList returns the built-in suffixes, with the extra suffixes
+// added or overriding their services, longest first so that the most
+// specific suffix matches. An extra suffix without a service keeps its
+// built-in service, or is named after itself.
+func takeoverSuffixList(extra map[string]string) []takeoverSuffix {
+	all := make(map[string]string, len(takeoverSuffixes)+len(extra))
+	for suffix, service := range takeoverSuffixes {
+		all[suffix] = service
+	}
+	for suffix, service := range extra {
+		suffix = strings.Trim(strings.ToLower(suffix), ".")
+		if service == "" {
+			service = all[suffix]
+		}
+		if service == "" {
+			service = suffix
+		}
+		all[suffix] = service
+	}
+
+	list := make([]takeoverSuffix, 0, len(all))
+	for suffix, service := range all {
+		list = append(list, takeoverSuffix{dns.SplitDomainName(suffix), service})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if len(list[i].labels) != len(list[j].labels) {
+			return len(list[i].labels) > len(list[j].labels)
+		}
+		return strings.Join(list[i].labels, ".") < strings.Join(list[j].labels, ".")
+	})
+	return list
+}
+
+// takeoverService returns the service of the first suffix the name ends in,
+// by whole labels.
+func takeoverService(suffixes []takeoverSuffix, name string) (string, bool) {
+	labels := dns.SplitDomainName(strings.ToLower(name))
+	for _, suffix := range suffixes {
+		if len(suffix.labels) > len(labels) {
+			continue
+		}
+		tail := labels[len(labels)-len(suffix.labels):]
+		matches := true
+		for i, pattern := range suffix.labels {
+			if ok, err := path.Match(pattern, tail[i]); err != nil || !ok {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return suffix.service, true
+		}
+	}
+	return "", false
+}
+
+// aliasCommentPattern matches the comments tfz53 export writes for alias
+// records, which zone files cannot describe.
+var aliasCommentPattern = regexp.MustCompile(`^\s*;\s*alias (\S+) (\S+) -> (\S+)`)
+
+// aliasComments returns the alias records of the comments in the zone file,
+// with their targets as data.
+func aliasComments(src []byte, fileName string) []dnsRecord {
+	var aliases []dnsRecord
+	for i, line := range bytes.Split(src, []byte("\n")) {
+		m := aliasCommentPattern.FindSubmatch(line)
+		if m == nil {
+			continue
+		}
+		aliases = append(aliases, dnsRecord{
+			Name: dns.Fqdn(strings.ToLower(string(m[1]))),
+			Type: string(m[2]),
+			Data: []string{dns.Fqdn(strings.ToLower(string(m[3])))},
+			File: fileName,
+			Line: i + 1,
+		})
+	}
+	return aliases
+}
+
+// lintSubdomainTakeover reports CNAME records and aliases pointing at
+// endpoints of services prone to subdomain takeover, which anyone can claim
+// once the resource behind them is deprovisioned.
+func lintSubdomainTakeover(z *lintZone, report lintReporter) {
+	for _, key := range z.keys {
+		if key.Type != "CNAME" {
+			continue
+		}
+		rec := z.records[key]
+		for _, target := range recordTargets(rec) {
+			if service, ok := takeoverService(z.takeover, target); ok {
+				report(rec, "confirm the "+service+" resource is still owned, or remove the record",
+					"%s CNAME points at %s, an endpoint of %s that can be taken over once deprovisioned", rec.Name, target, service)
+			}
+		}
+	}
+	for _, alias := range z.aliases {
+		if service, ok := takeoverService(z.takeover, alias.Data[0]); ok {
+			report(alias, "confirm the "+service+" resource is still owned, or remove the alias",
+				"Alias %s %s points at %s, an endpoint of %s that can be taken over once deprovisioned", alias.Name, alias.Type, alias.Data[0], service)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package converter
+
+import "fmt"
+
+// ttlPolicy resolves conflicting TTLs of the records of a record set, as
+// providers have a single TTL per set.
+type ttlPolicy string
+
+const (
+	// ttlFirst keeps the TTL of the first record of the set.
+	ttlFirst ttlPolicy = "first"
+	// ttlLast takes the TTL of the last record of the set.
+	ttlLast ttlPolicy = "last"
+	// ttlMin and ttlMax take the lowest and highest TTL of the set.
+	ttlMin ttlPolicy = "min"
+	ttlMax ttlPolicy = "max"
+	// ttlFail leaves out record sets with conflicting TTLs.
+	ttlFail ttlPolicy = "fail"
+
+	defaultTTLPolicy = ttlFirst
+)
+
+func ttlPolicyFromString(s string) (ttlPolicy, error) {
+	switch p := ttlPolicy(s); p {
+	case "":
+		return defaultTTLPolicy, nil
+	case ttlFirst, ttlLast, ttlMin, ttlMax, ttlFail:
+		return p, nil
+	default:
+		return "", fmt.Errorf("Unknown TTL policy %q", s)
+	}
+}
+
+// resolve returns the TTL of a set with the TTL current, when a record with
+// the TTL next is merged into it.
+func (p ttlPolicy) resolve(current, next uint32) uint32 {
+	switch p {
+	case ttlLast:
+		return next
+	case ttlMin:
+		if next < current {
+			return next
+		}
+	case ttlMax:
+		if next > current {
+			return next
+		}
+	}
+	return current
+}
+
+// ttlSetReader tracks the lines the TTLs of record sets were taken from, to
+// report conflicts with both lines, and the sets left out by ttlFail.
+type ttlSetReader struct {
+	policy ttlPolicy
+	lines  map[recordKey]int
+	failed map[recordKey]bool
+}
+
+func newTTLSetReader(policy ttlPolicy) *ttlSetReader {
+	return &ttlSetReader{
+		policy: policy,
+		lines:  make(map[recordKey]int),
+		failed: make(map[recordKey]bool),
+	}
+}
+
+// add notes the first record of a set.
+func (t *ttlSetReader) add(key recordKey, record dnsRecord) {
+	t.lines[key] = record.Line
+}
+
+// merge returns the TTL of the set when the record is merged into it,
+// reporting a conflict.
+func (t *ttlSetReader) merge(key recordKey, set, record dnsRecord, diag *diagnostics) uint32 {
+	if set.TTL == record.TTL {
+		return set.TTL
+	}
+	conflict := fmt.Sprintf("TTL %d of %s %s conflicts with TTL %d%s", record.TTL, record.Name, record.Type, set.TTL, atLine(t.lines[key]))
+	if t.policy == ttlFail {
+		diag.recordErrorf(record, "%s, skipping the record set", conflict)
+		t.failed[key] = true
+		return set.TTL
+	}
+
+	ttl := t.policy.resolve(set.TTL, record.TTL)
+	diag.recordWarnf(record, "%s, using %d", conflict, ttl)
+	if ttl != set.TTL {
+		t.lines[key] = record.Line
+	}
+	return ttl
+}
+
+// atLine refers to a line of the zone file in messages, if known.
+func atLine(line int) string {
+	if line == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" at line %d", line)
+}
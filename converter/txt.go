@@ -0,0 +1,258 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// txtMaxStringLength is the most bytes a character-string can hold, as its
+// length is a single byte in the wire format.
+const txtMaxStringLength = 255
+
+// TXT and SPF data are a list of character-strings. The record data of
+// dnsRecord holds them in zone file notation, each quoted and separated by a
+// space, with quotes and backslashes escaped and bytes outside printable
+// ASCII written as \DDD decimal escapes. The providers convert them from
+// there, as Route 53 expects octal escapes, and the Terraform AWS provider
+// joins the strings of a value by "" instead.
+
+func isTXTType(rrType string) bool {
+	return rrType == "TXT" || rrType == "SPF"
+}
+
+// txtFromParsed returns the character-strings of a TXT or SPF record parsed by
+// miekg/dns, which keeps them escaped as in the zone file. As the parser splits
+// strings that are longer than 255 characters before unescaping them, which
+// can split an escape sequence, escaped strings of exactly that length are
+// joined with the next before splitting them again by their bytes.
+func txtFromParsed(txt []string) []string {
+	var strs []string
+	var joined []byte
+	for i, s := range txt {
+		joined = append(joined, unescapeTXT(s, false)...)
+		if len(s) == txtMaxStringLength && strings.Contains(s, `\`) && i+1 < len(txt) {
+			continue
+		}
+		strs = append(strs, string(joined))
+		joined = nil
+	}
+	return splitTXT(strs)
+}
+
+// splitTXT splits character-strings longer than 255 bytes into several,
+// keeping the others as they are, as DKIM keys and other values can depend
+// on where their strings are split.
+func splitTXT(strs []string) []string {
+	split := make([]string, 0, len(strs))
+	for _, s := range strs {
+		for len(s) > txtMaxStringLength {
+			split = append(split, s[:txtMaxStringLength])
+			s = s[txtMaxStringLength:]
+		}
+		split = append(split, s)
+	}
+	return split
+}
+
+// formatTXT quotes and escapes the character-strings, separating them by
+// spaces. Bytes outside printable ASCII are escaped in decimal, as in zone
+// files, or in octal, as Route 53 expects.
+func formatTXT(strs []string, octal bool) string {
+	quoted := make([]string, len(strs))
+	for i, s := range strs {
+		quoted[i] = `"` + escapeTXT(s, octal) + `"`
+	}
+	return strings.Join(quoted, " ")
+}
+
+func escapeTXT(s string, octal bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' || c > '~':
+			if octal {
+				fmt.Fprintf(&b, `\%03o`, c)
+			} else {
+				fmt.Fprintf(&b, `\%03d`, c)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// unescapeTXT returns the bytes of an escaped character-string, where \DDD is
+// a decimal or octal byte, and a backslash otherwise quotes the next
+// character.
+func unescapeTXT(s string, octal bool) []byte {
+	base := 10
+	if octal {
+		base = 8
+	}
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b = append(b, s[i])
+			continue
+		}
+		if i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], base, 8); err == nil {
+				b = append(b, byte(n))
+				i += 3
+				continue
+			}
+		}
+		if i+1 < len(s) {
+			i++
+			b = append(b, s[i])
+		}
+	}
+	return b
+}
+
+// parseTXT splits quoted, escaped character-strings, separated by spaces or
+// not at all, as in "a" "b" or "a""b". Unquoted text is a string of its own up
+// to the next space.
+func parseTXT(data string, octal bool) []string {
+	var strs []string
+	for i := 0; i < len(data); {
+		switch data[i] {
+		case ' ', '\t':
+			i++
+			continue
+		case '"':
+			end := i + 1
+			for end < len(data) && data[end] != '"' {
+				if data[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end > len(data) {
+				end = len(data)
+			}
+			strs = append(strs, string(unescapeTXT(data[i+1:end], octal)))
+			i = end + 1
+		default:
+			end := i
+			for end < len(data) && data[end] != ' ' && data[end] != '\t' && data[end] != '"' {
+				if data[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end > len(data) {
+				end = len(data)
+			}
+			strs = append(strs, string(unescapeTXT(data[i:end], octal)))
+			i = end
+		}
+	}
+	return strs
+}
+
+// txtStrings returns the character-strings of TXT or SPF record data.
+func txtStrings(data string) []string {
+	return parseTXT(data, false)
+}
+
+// route53TXT converts TXT or SPF record data to the notation of the Route 53
+// API.
+func route53TXT(data string) string {
+	return formatTXT(txtStrings(data), true)
+}
+
+// terraformTXT converts TXT or SPF record data to a value of the records
+// attribute of the Terraform AWS provider, which quotes the value before
+// passing it to Route 53, so that strings are separated by "".
+func terraformTXT(data string) string {
+	strs := txtStrings(data)
+	escaped := make([]string, len(strs))
+	for i, s := range strs {
+		escaped[i] = escapeTXT(s, true)
+	}
+	return strings.Join(escaped, `""`)
+}
+
+// txtFromTerraform converts a value of the records attribute of the Terraform
+// AWS provider back to TXT or SPF record data. The provider quotes the value,
+// so its strings are separated by "", or " " as written by hand. Other quotes
+// are taken literally.
+func txtFromTerraform(value string) string {
+	var strs []string
+	var s []byte
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '\\' && i+1 < len(value):
+			if i+3 < len(value) {
+				if n, err := strconv.ParseUint(value[i+1:i+4], 8, 8); err == nil {
+					s = append(s, byte(n))
+					i += 3
+					continue
+				}
+			}
+			i++
+			s = append(s, value[i])
+		case c == '"':
+			end := i + 1
+			for end < len(value) && (value[end] == ' ' || value[end] == '\t') {
+				end++
+			}
+			if end == len(value) || value[end] != '"' {
+				s = append(s, c)
+				continue
+			}
+			strs = append(strs, string(s))
+			s = nil
+			i = end
+		default:
+			s = append(s, c)
+		}
+	}
+	strs = append(strs, string(s))
+	return formatTXT(splitTXT(strs), false)
+}
+
+// joinedTXT joins the character-strings of TXT or SPF record data into a
+// single string, for providers that split long values by themselves.
+func joinedTXT(data string) string {
+	return strings.Join(txtStrings(data), "")
+}
+
+// terraformString quotes a string for Terraform, escaping interpolation and
+// directive sequences and characters that cannot appear in string literals.
+func terraformString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case (r == '$' || r == '%') && strings.HasPrefix(s[i+1:], "{"):
+			b.WriteRune(r)
+			b.WriteRune(r)
+		case r < ' ' || r == utf8.RuneError && size == 1:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+		i += size
+	}
+	b.WriteByte('"')
+	return b.String()
+}
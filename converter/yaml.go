@@ -0,0 +1,159 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// yamlMap is a mapping whose keys are written in order. Together with slices,
+// strings, numbers, booleans and yamlTagged values it describes documents
+// written by writeYAML and writeJSON.
+type yamlMap []yamlField
+
+type yamlField struct {
+	Key   string
+	Value interface{}
+}
+
+// yamlTagged is a scalar or sequence carrying a local tag, such as the short
+// form intrinsic functions of CloudFormation (!Ref, !GetAtt).
+type yamlTagged struct {
+	Tag   string
+	Value interface{}
+}
+
+var yamlPlainScalar = regexp.MustCompile(`^[A-Za-z_/][A-Za-z0-9_./:@-]*$`)
+
+// yamlReserved lists plain scalars YAML parsers interpret as something other
+// than a string.
+var yamlReserved = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true,
+	"null": true, "y": true, "n": true,
+}
+
+// writeYAML writes the document in block style. Tagged sequences use flow
+// style, as is customary for CloudFormation's short form functions.
+func writeYAML(w io.Writer, doc yamlMap) error {
+	var buf bytes.Buffer
+	writeYAMLMap(&buf, doc, 0, false)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// writeYAMLMap writes the keys of the map at the given indentation. When the
+// map is an item of a sequence, its first key goes on the line of the dash.
+func writeYAMLMap(buf *bytes.Buffer, m yamlMap, indent int, firstInline bool) {
+	for i, f := range m {
+		if i > 0 || !firstInline {
+			buf.WriteString(strings.Repeat(" ", indent))
+		}
+		buf.WriteString(yamlScalar(f.Key))
+		buf.WriteString(":")
+		writeYAMLValue(buf, f.Value, indent)
+	}
+}
+
+func writeYAMLValue(buf *bytes.Buffer, v interface{}, indent int) {
+	switch v := v.(type) {
+	case yamlMap:
+		if len(v) == 0 {
+			buf.WriteString(" {}\n")
+			return
+		}
+		buf.WriteString("\n")
+		writeYAMLMap(buf, v, indent+2, false)
+	case []interface{}:
+		if len(v) == 0 {
+			buf.WriteString(" []\n")
+			return
+		}
+		buf.WriteString("\n")
+		for _, item := range v {
+			buf.WriteString(strings.Repeat(" ", indent+2))
+			buf.WriteString("-")
+			if m, ok := item.(yamlMap); ok && len(m) > 0 {
+				buf.WriteString(" ")
+				writeYAMLMap(buf, m, indent+4, true)
+				continue
+			}
+			writeYAMLValue(buf, item, indent+2)
+		}
+	default:
+		buf.WriteString(" ")
+		buf.WriteString(yamlFlow(v))
+		buf.WriteString("\n")
+	}
+}
+
+// yamlFlow renders a scalar, tagged value or sequence of those on one line.
+func yamlFlow(v interface{}) string {
+	switch v := v.(type) {
+	case yamlTagged:
+		return v.Tag + " " + yamlFlow(v.Value)
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = yamlFlow(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case string:
+		return yamlScalar(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func yamlScalar(s string) string {
+	if yamlPlainScalar.MatchString(s) && !yamlReserved[strings.ToLower(s)] {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+// writeJSON writes the document as indented JSON, keeping the key order.
+// Tagged values have no JSON representation, and must be converted by the
+// caller before.
+func writeJSON(w io.Writer, doc yamlMap) error {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "  "); err != nil {
+		return err
+	}
+	buf.WriteString("\n")
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+func (m yamlMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, f := range m {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		key, err := json.Marshal(f.Key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteString(":")
+		if _, ok := f.Value.(yamlTagged); ok {
+			return nil, fmt.Errorf("Cannot write tagged value of %s as JSON", f.Key)
+		}
+		value, err := json.Marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,82 @@
+package converter
+
+import "bytes"
+
+// recordLines returns the line each record of the zone file starts at, in
+// order, as the parser does not report them. It follows the master file
+// format closely enough to tell records from directives, blank lines and
+// comments, including records continued over several lines in parentheses.
+// Returns nil when the file includes other files or generates records, as
+// the records then no longer map to lines of the file.
+func recordLines(src []byte) []int {
+	var lines []int
+	line, start := 1, 0
+	var first []byte
+	inFirst, inQuote, inComment, depth := false, false, false, 0
+
+	// endEntry records the entry ending at a newline outside parentheses,
+	// unless it is a directive
+	endEntry := func() bool {
+		if start > 0 {
+			switch {
+			case first[0] != '$':
+				lines = append(lines, start)
+			case bytes.EqualFold(first, []byte("$INCLUDE")) || bytes.EqualFold(first, []byte("$GENERATE")):
+				return false
+			}
+		}
+		start, first, inFirst = 0, first[:0], false
+		return true
+	}
+
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			inComment = false
+			if !inQuote && depth == 0 && !endEntry() {
+				return nil
+			}
+			continue
+		case inComment:
+			continue
+		case c == '\\':
+			// The escaped character is part of the token, and never
+			// starts a comment or a string
+			if i+1 < len(src) && src[i+1] == '\n' {
+				line++
+			}
+			i++
+		case inQuote:
+			if c == '"' {
+				inQuote = false
+			}
+		case c == ';':
+			inComment, inFirst = true, false
+			continue
+		case c == '"':
+			inQuote = true
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth > 0 {
+				depth--
+			}
+		case c == ' ' || c == '\t' || c == '\r':
+			inFirst = false
+			continue
+		}
+
+		if start == 0 {
+			start, inFirst = line, true
+		}
+		if inFirst {
+			first = append(first, c)
+		}
+	}
+	if !endEntry() {
+		return nil
+	}
+	return lines
+}
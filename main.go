@@ -1,17 +1,17 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
-	"sort"
 	"strings"
-	"text/template"
 
-	"github.com/miekg/dns"
-	"golang.org/x/net/idna"
+	"github.com/carlpett/tfz53/converter"
 )
 
 // Build information. Populated at build-time.
@@ -23,322 +23,326 @@ var (
 	BuildDate string
 )
 
-const (
-	zoneTemplateStr = `resource "aws_route53_zone" "{{ .ID }}" {
-  name = "{{ .Domain }}"
-}
-`
-	recordTemplateStr = `{{- range .Record.Comments }}
-# {{ . }}{{ end }}
-resource "aws_route53_record" "{{ .ResourceID }}" {
-  zone_id = {{ zoneReference .ZoneID }}
-  name    = "{{ .Record.Name }}"
-  type    = "{{ .Record.Type }}"
-  ttl     = "{{ .Record.TTL }}"
-  records = [{{ range $idx, $elem := .Record.Data }}{{ if $idx }}, {{ end }}{{ ensureQuoted $elem }}{{ end }}]
-}
-`
-)
-
-type syntaxMode uint8
-
-func (m syntaxMode) String() string {
-	switch m {
-	case Modern:
-		return "modern"
-	case Legacy:
-		return "legacy"
-	default:
-		panic("Unknown syntax")
-	}
-}
-
-const (
-	Modern syntaxMode = iota
-	Legacy
-)
-
-type configGenerator struct {
-	zoneTemplate   *template.Template
-	recordTemplate *template.Template
-
-	syntax syntaxMode
-}
-
-func newConfigGenerator(syntax syntaxMode) *configGenerator {
-	g := &configGenerator{syntax: syntax}
-	g.zoneTemplate = template.Must(template.New("zone").Parse(zoneTemplateStr))
-	g.recordTemplate = template.Must(template.New("record").Funcs(template.FuncMap{
-		"ensureQuoted":  ensureQuoted,
-		"zoneReference": g.zoneReference,
-	}).Parse(recordTemplateStr))
-	return g
-}
-
-type zoneTemplateData struct {
-	ID     string
-	Domain string
-}
-type recordTemplateData struct {
-	ResourceID string
-	Record     dnsRecord
-	ZoneID     string
-}
-type dnsRecord struct {
-	Name     string
-	Type     string
-	TTL      uint32
-	Data     []string
-	Comments []string
-}
-type recordKey struct {
-	Name string
-	Type string
-}
-type recordKeySlice []recordKey
-
-func (records recordKeySlice) Len() int {
-	return len(records)
-}
-func (records recordKeySlice) Less(i, j int) bool {
-	genKey := func(k recordKey) string {
-		return fmt.Sprintf("%s-%s", k.Name, k.Type)
-	}
-	return genKey(records[i]) < genKey(records[j])
-}
-func (records recordKeySlice) Swap(i, j int) {
-	tmp := records[i]
-	records[i] = records[j]
-	records[j] = tmp
-}
-
 var (
 	excludedTypesRaw = flag.String("exclude", "SOA,NS", "Comma-separated list of record types to ignore")
+	ttlPolicy        = flag.String("ttl-policy", "first", "TTL of record sets whose records have different TTLs (min, max, first, last), or fail to leave them out")
 	domain           = flag.String("domain", "", "Name of domain")
 	zoneFile         = flag.String("zone-file", "", "Path to zone file. Defaults to <domain>.zone in working dir")
 	showVersion      = flag.Bool("version", false, "Show version")
 	legacySyntax     = flag.Bool("legacy-syntax", false, "Generate legacy terraform syntax (versions older than 0.12)")
+	providerName     = flag.String("provider", "route53", "DNS provider to generate resources for")
+	resourceGroup    = flag.String("azure-resource-group", "", "Resource group of the Azure DNS zone. Required for the azure provider")
+	mirrorName       = flag.String("mirror", "", "Second DNS provider to serve the zone from, generating identical record sets for both")
+	mirrorReport     = flag.String("mirror-report", "", "Path to write the mirror consistency report to. Defaults to stderr")
+	outputFormat     = flag.String("format", "terraform", "Output format (terraform, cloudformation-yaml, cloudformation-json, pulumi-yaml, route53-changebatch)")
+	recordSetGroups  = flag.Bool("cloudformation-record-set-groups", false, "Group record sets into AWS::Route53::RecordSetGroup resources")
+	existingZone     = flag.Bool("existing-zone", false, "Add the records to an existing Route 53 hosted zone, looked up by name, instead of creating it")
+	deltaFrom        = flag.String("delta-from", "", "Path to a previous version of the zone file. Writes the changes from it to the zone file instead of generating resources")
+	deltaFormat      = flag.String("delta-format", "text", "Format of the changes written with -delta-from (text, json, route53-changebatch)")
+	hostedZoneID     = flag.String("hosted-zone-id", "", "ID of the Route 53 hosted zone to apply to. Defaults to the hosted zone named after the domain")
+	endpointURL      = flag.String("endpoint-url", "", "Route 53 API endpoint to apply to. Defaults to $AWS_ENDPOINT_URL_ROUTE_53, or the AWS endpoint")
+	autoApprove      = flag.Bool("auto-approve", false, "Apply without asking for confirmation")
+	outputPath       = flag.String("output", "", "Path to write the output to once it is complete, replacing the file atomically. Defaults to stdout")
+	checkOutput      = flag.Bool("check", false, "Instead of writing the output file, check that it is up to date, showing the differences otherwise")
+	previousPath     = flag.String("previous", "", "Previously generated Terraform file, or its state, to move the resources of renamed records from")
+	movedScript      = flag.String("moved-script", "", "Path to write the terraform state mv commands to in legacy syntax. Defaults to stderr")
+	terraformDir     = flag.String("tf-dir", ".", "Directory of the Terraform configuration to compare the zone file with")
+	stateFile        = flag.String("state", "terraform.tfstate", "Terraform state, or JSON state or plan from terraform show -json, to export the zone from")
+	namingStrategy   = flag.String("naming", "fqdn", "Resource naming strategy (fqdn, relative, hash), or a template like {{.Relative}}_{{.Type | lower}}")
+	strict           = flag.Bool("strict", false, "Treat warnings as errors")
+	diagnosticsFmt   = flag.String("diagnostics-format", "text", "Format of the diagnostics written to stderr (text, json)")
+	lintFormat       = flag.String("lint-format", "text", "Format of the findings of lint (text, json, sarif)")
+	lintRules        = flag.String("lint-rules", "", "Comma-separated list of lint rules to configure, like low-ttl=error,empty-non-terminal=off")
+	lintMinTTL       = flag.Uint("lint-min-ttl", 60, "Lowest TTL the low-ttl lint rule accepts")
+	takeoverFile     = flag.String("takeover-suffixes", "", "File of suffixes of services prone to subdomain takeover for lint to add, one per line followed by the name of the service")
+	siblingZones     = flag.String("sibling-zones", "", "Comma-separated list of other zones of the batch that lint resolves targets in, as <domain> or <domain>=<zone file>")
 )
 
+// exitErrors is the exit code when records could not be converted. The
+// output is still written, without them.
+const exitErrors = 3
+
 func main() {
-	flag.Parse()
+	// Instead of generating resources, `tfz53 apply [flags]` pushes the zone
+	// to Route 53, `tfz53 export [flags]` writes a zone file from Terraform,
+	// `tfz53 diff [flags]` compares the zone file with the generated
+	// Terraform, and `tfz53 lint [flags]` checks the zone file for problems
+	var command string
+	args := os.Args[1:]
+	if len(args) > 0 && (args[0] == "apply" || args[0] == "export" || args[0] == "diff" || args[0] == "lint") {
+		command, args = args[0], args[1:]
+	}
+	flag.CommandLine.Parse(args)
 	if *showVersion {
 		fmt.Printf("tfz53 %s (%s/%s) (%s on %s)", Version, Branch, Revision, BuildUser, BuildDate)
 		os.Exit(0)
 	}
 
-	if *domain == "" {
-		log.Fatal("Domain is required")
+	if *checkOutput && *outputPath == "" {
+		log.Fatal("Check mode requires an output file")
 	}
-	if *zoneFile == "" {
-		*zoneFile = fmt.Sprintf("%s.zone", *domain)
+	if *diagnosticsFmt != "text" && *diagnosticsFmt != "json" {
+		log.Fatalf("Unknown diagnostics format %q", *diagnosticsFmt)
 	}
-
-	excludedTypes := excludedTypesFromString(*excludedTypesRaw)
-
-	fileReader, err := os.Open(*zoneFile)
+	c, err := converter.New(configuredOptions())
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	var syntax syntaxMode
-	if !*legacySyntax {
-		syntax = Modern
-	} else {
-		syntax = Legacy
+	if command == "export" {
+		state, err := os.Open(*stateFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer state.Close()
+		res, err := c.Export(state, *hostedZoneID)
+		if err != nil {
+			log.Fatal(err)
+		}
+		logDiagnostics(res)
+		writeOutput(res)
+		exitOnErrors(res)
+		return
 	}
-	g := newConfigGenerator(syntax)
-	g.generateTerraformForZone(*domain, excludedTypes, fileReader, os.Stdout)
-}
-
-func (g *configGenerator) generateTerraformForZone(domain string, excludedTypes map[uint16]bool, zoneReader io.Reader, output io.Writer) {
-	records := readZoneRecords(zoneReader, excludedTypes)
 
-	zoneID, err := g.generateZoneResource(domain, output)
+	if *zoneFile == "" {
+		*zoneFile = fmt.Sprintf("%s.zone", *domain)
+	}
+	fileReader, err := os.Open(*zoneFile)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	recordKeys := make(recordKeySlice, 0, len(records))
-	for key := range records {
-		recordKeys = append(recordKeys, key)
-	}
-	sort.Sort(sort.Reverse(recordKeys))
-
-	for _, key := range recordKeys {
-		rec := records[key]
-		err := g.generateRecordResource(rec, zoneID, output)
+	var res *converter.Result
+	switch {
+	case command == "diff":
+		res, err = c.Diff(fileReader, *terraformDir)
 		if err != nil {
-			log.Printf("Error: %v\n", err)
-			continue
+			log.Fatal(err)
 		}
-	}
-}
-
-func readZoneRecords(zoneReader io.Reader, excludedTypes map[uint16]bool) map[recordKey]dnsRecord {
-	records := make(map[recordKey]dnsRecord)
-	for rr := range dns.ParseZone(zoneReader, *domain, *zoneFile) {
-		if rr.Error != nil {
-			log.Printf("Error: %v\n", rr.Error)
-			continue
+		logDiagnostics(res)
+		os.Stdout.Write(res.Output)
+		exitOnErrors(res)
+		if res.Changes > 0 {
+			// Like terraform plan -detailed-exitcode, errors exit with 1
+			os.Exit(2)
 		}
-
-		recordType := rr.Header().Rrtype
-		isExcluded, ok := excludedTypes[recordType]
-		if ok && isExcluded {
-			continue
+		return
+	case command == "lint":
+		opts := lintOptions()
+		res, err = c.Lint(fileReader, opts)
+		for _, sibling := range opts.Siblings {
+			sibling.Zone.(*os.File).Close()
 		}
-
-		record := generateRecord(rr)
-
-		key := recordKey{record.Name, record.Type}
-		if _, ok := records[key]; ok {
-			record = mergeRecords(records[key], record)
+		if err != nil {
+			log.Fatal(err)
+		}
+	case command == "apply":
+		res, err = c.Apply(fileReader, applyOptions())
+		if err != nil {
+			log.Fatal(err)
+		}
+		logDiagnostics(res)
+		exitOnErrors(res)
+		return
+	case *deltaFrom != "":
+		oldReader, err := os.Open(*deltaFrom)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer oldReader.Close()
+		res, err = c.Delta(oldReader, fileReader, *deltaFormat)
+		if err != nil {
+			log.Fatal(err)
+		}
+	default:
+		res, err = c.Convert(fileReader)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *mirrorName != "" {
+			writeFile(*mirrorReport, res.MirrorReport)
+		}
+		if len(res.MovedScript) > 0 {
+			writeFile(*movedScript, res.MovedScript)
 		}
-
-		records[key] = record
 	}
-	return records
+	logDiagnostics(res)
+	writeOutput(res)
+	exitOnErrors(res)
 }
 
-func (g *configGenerator) generateZoneResource(domain string, w io.Writer) (string, error) {
-	zoneName := strings.TrimRight(domain, ".")
-	data := zoneTemplateData{
-		ID:     strings.Replace(zoneName, ".", "-", -1),
-		Domain: zoneName,
+// configuredOptions returns the converter options set by the flags.
+func configuredOptions() converter.Options {
+	opts := converter.Options{
+		Domain:             *domain,
+		Exclude:            strings.Split(*excludedTypesRaw, ","),
+		TTLPolicy:          *ttlPolicy,
+		Naming:             *namingStrategy,
+		Provider:           *providerName,
+		AzureResourceGroup: *resourceGroup,
+		Mirror:             *mirrorName,
+		ExistingZone:       *existingZone,
+		Format:             *outputFormat,
+		RecordSetGroups:    *recordSetGroups,
+		Strict:             *strict,
 	}
-
-	err := g.zoneTemplate.Execute(w, data)
-	return data.ID, err
-}
-
-func (g *configGenerator) generateRecordResource(record dnsRecord, zoneID string, w io.Writer) error {
-	sanitizedName := sanitizeRecordName(record.Name)
-	id := fmt.Sprintf("%s-%s", sanitizedName, record.Type)
-
-	data := recordTemplateData{
-		ResourceID: id,
-		Record:     record,
-		ZoneID:     zoneID,
+	if *legacySyntax {
+		opts.Syntax = converter.Legacy
 	}
-
-	return g.recordTemplate.Execute(w, data)
-}
-
-func mergeRecords(a, b dnsRecord) dnsRecord {
-	a.Data = append(a.Data, b.Data...)
-	a.Comments = append(a.Comments, b.Comments...)
-
-	return a
+	if *previousPath != "" {
+		previous, err := ioutil.ReadFile(*previousPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.Previous, opts.PreviousName = previous, *previousPath
+	}
+	return opts
 }
 
-func generateRecord(rr *dns.Token) dnsRecord {
-	header := rr.Header()
-	name := strings.ToLower(header.Name)
-
-	key := recordKey{
-		Name: name,
-		Type: dns.TypeToString[header.Rrtype],
+// applyOptions returns the options of apply. Credentials are found by the
+// AWS SDK, like the AWS CLI finds them.
+func applyOptions() converter.ApplyOptions {
+	return converter.ApplyOptions{
+		HostedZoneID: *hostedZoneID,
+		Endpoint:     *endpointURL,
+		In:           os.Stdin,
+		Out:          os.Stdout,
+		AutoApprove:  *autoApprove,
 	}
+}
 
-	data := strings.TrimPrefix(rr.String(), header.String())
-	if key.Type == "CNAME" {
-		data = strings.ToLower(data)
+// lintOptions returns the options of lint, opening the zone files of the
+// sibling zones, which are closed once Lint returns.
+func lintOptions() converter.LintOptions {
+	opts := converter.LintOptions{
+		Format: *lintFormat,
+		Rules:  make(map[string]string),
+		MinTTL: uint32(*lintMinTTL),
 	}
-
-	if key.Type == "TXT" {
-		// TXT records can be up to 255 characters long in BIND format. Cloud
-		// DNS Terraform providers lets them be longer by joining them with
-		// a \"\" sequence. So we split by " " (which is inserted by miekg/dns
-		// unless already in the source file), trim away any spaces, then join
-		// by the escape sequence. So the following:
-		// foo IN TXT "long-[250 chars]-string"
-		// ... will be hava a data section like this before being adjusted:
-		// "long-[250 chars]" "-string"
-		// Below, we merge this into
-		// "long-[250 chars]\"\"-string"
-		// Which is then properly passed from Terraform to Route 53
-		parts := strings.Split(data, `" "`)
-		for pidx := range parts {
-			parts[pidx] = strings.TrimSpace(parts[pidx])
+	if *lintRules != "" {
+		for _, rule := range strings.Split(*lintRules, ",") {
+			parts := strings.SplitN(rule, "=", 2)
+			if len(parts) != 2 {
+				log.Fatalf("Lint rule %q must be configured like <rule>=<severity>", rule)
+			}
+			opts.Rules[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
 		}
-		data = strings.Join(parts, `\"\"`)
 	}
-
-	comments := make([]string, 0)
-	if rr.Comment != "" {
-		comments = append(comments, strings.TrimLeft(rr.Comment, ";"))
+	if *takeoverFile != "" {
+		opts.TakeoverSuffixes = readTakeoverSuffixes(*takeoverFile)
 	}
-	return dnsRecord{
-		Name:     key.Name,
-		Type:     key.Type,
-		TTL:      header.Ttl,
-		Data:     []string{data},
-		Comments: comments,
+	if *siblingZones != "" {
+		for _, zone := range strings.Split(*siblingZones, ",") {
+			parts := strings.SplitN(zone, "=", 2)
+			sibling := converter.SiblingZone{Domain: strings.TrimSpace(parts[0])}
+			sibling.FileName = fmt.Sprintf("%s.zone", sibling.Domain)
+			if len(parts) == 2 {
+				sibling.FileName = strings.TrimSpace(parts[1])
+			}
+			f, err := os.Open(sibling.FileName)
+			if err != nil {
+				log.Fatal(err)
+			}
+			sibling.Zone = f
+			opts.Siblings = append(opts.Siblings, sibling)
+		}
 	}
+	return opts
 }
 
-// sanitizeRecordName creates a normalized record name that Terraform accepts.
-// Terraform only allows letters, numbers, dashes and underscores, while DNS
-// records allow far more.
-// 1. All dots are replaced with -
-// 2. * is replaced by the string "wildcard"
-// 3. IDN records are cleaned using punycode conversion
-// 4. Any remaining non-allowed characters are replaced underscore
-// 5. If the start of the record name is not a valid Terraform identifier,
-//    then prepend an underscore.
-func sanitizeRecordName(name string) string {
-	withoutDots := strings.Replace(strings.TrimRight(name, "."), ".", "-", -1)
-	withoutAsterisk := strings.Replace(withoutDots, "*", "wildcard", -1)
-
-	punycoded, err := idna.Punycode.ToASCII(withoutAsterisk)
+// readTakeoverSuffixes reads a file of suffixes of services prone to
+// subdomain takeover, with a suffix per line followed by the name of the
+// service, if any. Blank lines and lines starting with # are ignored.
+func readTakeoverSuffixes(path string) map[string]string {
+	f, err := os.Open(path)
 	if err != nil {
-		log.Fatalf("Cannot create resource name from record %s: %v", name, err)
+		log.Fatal(err)
 	}
+	defer f.Close()
 
-	id := strings.Map(func(r rune) rune {
-		if (r >= 'a' && r <= 'z') ||
-			(r >= 'A' && r <= 'z') ||
-			(r >= '0' && r <= '9') ||
-			(r == '-' || r == '_') {
-			return r
+	suffixes := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
 		}
-		return '_'
-	}, punycoded)
+		fields := strings.Fields(line)
+		suffixes[fields[0]] = strings.Join(fields[1:], " ")
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+	return suffixes
+}
 
-	if (id[0] >= 'a' && id[0] <= 'z') ||
-		(id[0] >= 'A' && id[0] <= 'Z') ||
-		(id[0] == '_') {
-		return id
+// logDiagnostics writes the diagnostics to stderr. As text, the warnings and
+// errors are followed by a summary, while JSON also includes the records that
+// were excluded.
+func logDiagnostics(res *converter.Result) {
+	errors, warnings := res.Count(converter.Error), res.Count(converter.Warning)
+	if *diagnosticsFmt == "json" {
+		diagnostics := res.Diagnostics
+		if diagnostics == nil {
+			diagnostics = []converter.Diagnostic{}
+		}
+		enc := json.NewEncoder(os.Stderr)
+		enc.SetIndent("", "  ")
+		err := enc.Encode(struct {
+			Diagnostics []converter.Diagnostic `json:"diagnostics"`
+			Errors      int                    `json:"errors"`
+			Warnings    int                    `json:"warnings"`
+		}{diagnostics, errors, warnings})
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	return fmt.Sprintf("_%s", id)
+	for _, d := range res.Diagnostics {
+		if d.Severity != converter.Info {
+			log.Print(d)
+		}
+	}
+	if errors > 0 || warnings > 0 {
+		log.Printf("%d errors, %d warnings", errors, warnings)
+	}
 }
 
-func excludedTypesFromString(s string) map[uint16]bool {
-	excludedTypes := make(map[uint16]bool)
-	for _, t := range strings.Split(s, ",") {
-		t = strings.ToUpper(t) // ensure upper case
-		rrType := dns.StringToType[t]
-		excludedTypes[rrType] = true
+// exitOnErrors exits with exitErrors when records could not be converted, or
+// with -strict, had warnings, and when lint found errors.
+func exitOnErrors(res *converter.Result) {
+	if hasErrors(res) {
+		os.Exit(exitErrors)
 	}
-	return excludedTypes
 }
 
-func ensureQuoted(s string) string {
-	if s[0] == '"' && s[len(s)-1] == '"' {
-		return s
+func hasErrors(res *converter.Result) bool {
+	if res.Count(converter.Error) > 0 {
+		return true
+	}
+	for _, f := range res.Findings {
+		if f.Severity == converter.Error {
+			return true
+		}
 	}
-	return fmt.Sprintf("%q", s)
+	return false
 }
 
-func (g *configGenerator) zoneReference(zone string) string {
-	switch g.syntax {
-	case Modern:
-		return fmt.Sprintf("aws_route53_zone.%s.zone_id", zone)
-	case Legacy:
-		return fmt.Sprintf(`"${aws_route53_zone.%s.zone_id}"`, zone)
-	default:
-		panic(fmt.Sprintf("Unknown mode %v", g.syntax))
+// writeFile writes secondary output, such as a report, to the file at path,
+// or to stderr when no path is given.
+func writeFile(path string, data []byte) {
+	var w io.Writer = os.Stderr
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if _, err := w.Write(data); err != nil {
+		log.Fatal(err)
 	}
 }
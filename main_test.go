@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -12,123 +11,75 @@ import (
 	"github.com/google/go-cmp/cmp"
 )
 
-var (
-	diffOpts = cmp.Options{
-		cmp.Transformer("ignoreSurroundingWhitespace", func(in string) string {
-			return strings.TrimSpace(in)
-		}),
-	}
-)
-
-func caseName(name string, syntax syntaxMode) string {
-	return fmt.Sprintf("%s-%v", name, syntax)
-}
-
-func TestGenerateRecordResource(t *testing.T) {
-	record := dnsRecord{
-		Name:     "foo.bar",
-		Data:     []string{"127.0.0.1"},
-		Type:     "A",
-		TTL:      3600,
-		Comments: []string{"This is a test"},
-	}
-
-	cases := []struct {
-		name     string
-		expected map[syntaxMode]string
-	}{
-		{
-			name: "basic",
-			expected: map[syntaxMode]string{
-				Modern: `# This is a test
-resource "aws_route53_record" "foo-bar-A" {
-  zone_id = aws_route53_zone.test-zone.zone_id
-  name    = "foo.bar"
-  type    = "A"
-  ttl     = "3600"
-  records = ["127.0.0.1"]
-}`,
-				Legacy: `# This is a test
-resource "aws_route53_record" "foo-bar-A" {
-  zone_id = "${aws_route53_zone.test-zone.zone_id}"
-  name    = "foo.bar"
-  type    = "A"
-  ttl     = "3600"
-  records = ["127.0.0.1"]
-}`,
-			},
-		},
-	}
-	for _, tc := range cases {
-		for _, legacySyntax := range []syntaxMode{Modern, Legacy} {
-			t.Run(caseName(tc.name, legacySyntax), func(t *testing.T) {
-				g := newConfigGenerator(legacySyntax)
-
-				var buf bytes.Buffer
-				err := g.generateRecordResource(record, "test-zone", &buf)
-				if err != nil {
-					t.Fatal(err)
-				}
-
-				if diff := cmp.Diff(tc.expected[legacySyntax], buf.String(), diffOpts); diff != "" {
-					t.Errorf("Unexpected result from resource generation (-want +got):\n%s", diff)
-				}
-			})
+func TestUnifiedDiff(t *testing.T) {
+	var a, b []string
+	for i := 1; i <= 20; i++ {
+		a = append(a, fmt.Sprint(i))
+		switch i {
+		case 2:
+			b = append(b, "two")
+		case 15:
+		default:
+			b = append(b, fmt.Sprint(i))
 		}
 	}
-}
+	b = append(b, "21")
 
-func TestResourceNameSanitation(t *testing.T) {
-	cases := []struct {
-		name           string
-		expectedOutput string
-	}{
-		{"foo.bar.com", "foo-bar-com"},
-		{"*.bar.com", "wildcard-bar-com"},
-		{"åäö.bar.com", "xn---bar-com-zzaj2q"},
-		{"#issue-2.github.com", "_issue-2-github-com"},
-		{"//issue-2.github.com", "__issue-2-github-com"},
-		{"12-issue-12.github.com", "_12-issue-12-github-com"},
+	expected := `--- old
++++ new
+@@ -1,5 +1,5 @@
+ 1
+-2
++two
+ 3
+ 4
+ 5
+@@ -12,9 +12,9 @@
+ 12
+ 13
+ 14
+-15
+ 16
+ 17
+ 18
+ 19
+ 20
++21
+`
+	got := unifiedDiff("old", "new", strings.Join(a, "\n")+"\n", strings.Join(b, "\n")+"\n")
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("Unexpected unified diff (-want +got):\n%s", diff)
 	}
-
-	for _, c := range cases {
-		t.Run(c.name, func(t *testing.T) {
-			id := sanitizeRecordName(c.name)
-			if id != c.expectedOutput {
-				t.Errorf("Expected %q, got %q", c.expectedOutput, id)
-			}
-		})
+	if got := unifiedDiff("old", "new", "same\n", "same\n"); got != "" {
+		t.Errorf("Expected no differences, got:\n%s", got)
 	}
 }
 
-func TestAcceptance(t *testing.T) {
-	fileNames, err := filepath.Glob("testdata/*.zone")
+func TestWriteFileAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfz53")
 	if err != nil {
 		panic(err)
 	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dns.tf")
+	if err := ioutil.WriteFile(path, []byte("old"), 0600); err != nil {
+		panic(err)
+	}
 
-	for _, n := range fileNames {
-		for _, syntax := range []syntaxMode{Modern, Legacy} {
-			t.Run(caseName(n, syntax), func(t *testing.T) {
-				file, err := os.Open(n)
-				if err != nil {
-					panic(err)
-				}
-				expected, err := ioutil.ReadFile(strings.Replace(n, ".zone", fmt.Sprintf(".expected-%v", syntax), 1))
-				if err != nil {
-					panic(err)
-				}
-
-				g := newConfigGenerator(syntax)
-				var buf bytes.Buffer
-				domain := strings.Replace(filepath.Base(n), ".zone", "", 1)
-				excludedTypes := excludedTypesFromString("SOA,NS")
-				g.generateTerraformForZone(domain, excludedTypes, file, &buf)
-
-				if diff := cmp.Diff(string(expected), buf.String(), diffOpts); diff != "" {
-					t.Errorf("Unexpected result from full Terraform output (-want +got):\n%s", diff)
-				}
-			})
-		}
+	if err := writeFileAtomic(path, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "new" {
+		t.Errorf("Expected the file to be replaced, got %q", content)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the mode of the file to be kept, got %v", info.Mode())
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("Expected the temporary file to be renamed, found %d files", len(files))
 	}
 }
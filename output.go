@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/carlpett/tfz53/converter"
+)
+
+// writeOutput writes the complete output to stdout, or replaces the -output
+// file with it. In check mode, the file is compared with the output instead,
+// and the differences are shown and exit with 1 when they do not match. The
+// file is left unchanged when records were left out with errors.
+func writeOutput(res *converter.Result) {
+	data := res.Output
+	switch {
+	case *outputPath != "" && hasErrors(res):
+		log.Printf("Leaving %s unchanged, as records were left out with errors", *outputPath)
+	case *checkOutput:
+		current, err := ioutil.ReadFile(*outputPath)
+		if err != nil && !os.IsNotExist(err) {
+			log.Fatal(err)
+		}
+		if bytes.Equal(current, data) {
+			return
+		}
+		fmt.Print(unifiedDiff(*outputPath, *outputPath+" (generated)", string(current), string(data)))
+		log.Printf("Error: %s is not up to date\n", *outputPath)
+		os.Exit(1)
+	case *outputPath != "":
+		if err := writeFileAtomic(*outputPath, data); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		if _, err := os.Stdout.Write(data); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// writeFileAtomic replaces the file by writing a temporary file next to it and
+// renaming that, so that readers never see partial contents. The mode of an
+// existing file is kept.
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+const (
+	diffContextLines = 3
+	// diffMaxCells bounds the table used to find the common lines, above
+	// which the differing part is shown as replaced as a whole.
+	diffMaxCells = 1 << 24
+)
+
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// unifiedDiff returns the differences between a and b in unified format, or
+// an empty string when they are equal.
+func unifiedDiff(aName, bName, a, b string) string {
+	lines := diffLines(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	for start := 0; start < len(lines); {
+		// Find the next change, and the extent of its hunk
+		first := start
+		for first < len(lines) && lines[first].op == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+		}
+		from := first - diffContextLines
+		if from < start {
+			from = start
+		}
+		to, unchanged := first, 0
+		for i := first; i < len(lines) && unchanged <= 2*diffContextLines; i++ {
+			if lines[i].op == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+				to = i + 1
+			}
+		}
+		if to += diffContextLines; to > len(lines) {
+			to = len(lines)
+		}
+
+		aStart, bStart := 1, 1
+		for _, l := range lines[:from] {
+			if l.op != '+' {
+				aStart++
+			}
+			if l.op != '-' {
+				bStart++
+			}
+		}
+		var aCount, bCount int
+		for _, l := range lines[from:to] {
+			if l.op != '+' {
+				aCount++
+			}
+			if l.op != '-' {
+				bCount++
+			}
+		}
+		if aCount == 0 {
+			aStart--
+		}
+		if bCount == 0 {
+			bStart--
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+		for _, l := range lines[from:to] {
+			out.WriteByte(l.op)
+			out.WriteString(l.text)
+			out.WriteByte('\n')
+		}
+		start = to
+	}
+	return out.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines aligns the lines of a and b along their longest common
+// subsequence.
+func diffLines(a, b []string) []diffLine {
+	var prefix, suffix []diffLine
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		prefix = append(prefix, diffLine{' ', a[0]})
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		suffix = append([]diffLine{{' ', a[len(a)-1]}}, suffix...)
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+
+	var middle []diffLine
+	if (len(a)+1)*(len(b)+1) > diffMaxCells {
+		for _, l := range a {
+			middle = append(middle, diffLine{'-', l})
+		}
+		for _, l := range b {
+			middle = append(middle, diffLine{'+', l})
+		}
+	} else {
+		// lcs[i][j] is the length of the common subsequence of a[i:] and b[j:]
+		lcs := make([][]int, len(a)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else if lcs[i+1][j] >= lcs[i][j+1] {
+					lcs[i][j] = lcs[i+1][j]
+				} else {
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(a) || j < len(b) {
+			switch {
+			case i < len(a) && j < len(b) && a[i] == b[j]:
+				middle = append(middle, diffLine{' ', a[i]})
+				i++
+				j++
+			case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+				middle = append(middle, diffLine{'-', a[i]})
+				i++
+			default:
+				middle = append(middle, diffLine{'+', b[j]})
+				j++
+			}
+		}
+	}
+	return append(append(prefix, middle...), suffix...)
+}